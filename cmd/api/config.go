@@ -0,0 +1,111 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"gobid/internal/services"
+	"os"
+	"strconv"
+	"time"
+)
+
+// loadAuctionConfig starts from the default auction rules and applies any
+// override found in the environment.
+func loadAuctionConfig() (services.AuctionConfig, error) {
+	config := services.DefaultAuctionConfig()
+
+	if raw := os.Getenv("GOBID_BID_INCREMENTS"); raw != "" {
+		increments, err := services.ParseIncrementTable(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_BID_INCREMENTS: %w", err)
+		}
+		config.BidIncrements = increments
+	}
+
+	if raw := os.Getenv("GOBID_BUY_NOW_THRESHOLD_PERCENT"); raw != "" {
+		percent, err := strconv.Atoi(raw)
+		if err != nil || percent <= 0 || percent > 100 {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_BUY_NOW_THRESHOLD_PERCENT: must be between 1 and 100")
+		}
+		config.BuyNowThresholdPercent = percent
+	}
+
+	if raw := os.Getenv("GOBID_SOFT_CLOSE_WINDOW"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_SOFT_CLOSE_WINDOW: %w", err)
+		}
+		config.SoftCloseWindow = window
+	}
+
+	if raw := os.Getenv("GOBID_SOFT_CLOSE_EXTENSION"); raw != "" {
+		extension, err := time.ParseDuration(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_SOFT_CLOSE_EXTENSION: %w", err)
+		}
+		config.SoftCloseExtension = extension
+	}
+
+	if raw := os.Getenv("GOBID_SEALED_BID_REVISIONS"); raw != "" {
+		revisions, err := strconv.ParseBool(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_SEALED_BID_REVISIONS: %w", err)
+		}
+		config.SealedBidRevisions = revisions
+	}
+
+	if raw := os.Getenv("GOBID_CANCEL_CUTOFF"); raw != "" {
+		cutoff, err := time.ParseDuration(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_CANCEL_CUTOFF: %w", err)
+		}
+		config.CancelCutoff = cutoff
+	}
+
+	if raw := os.Getenv("GOBID_RETRACTION_WINDOW"); raw != "" {
+		window, err := time.ParseDuration(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_RETRACTION_WINDOW: %w", err)
+		}
+		config.RetractionWindow = window
+	}
+
+	if raw := os.Getenv("GOBID_RETRACTION_CUTOFF"); raw != "" {
+		cutoff, err := time.ParseDuration(raw)
+		if err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_RETRACTION_CUTOFF: %w", err)
+		}
+		config.RetractionCutoff = cutoff
+	}
+
+	if raw := os.Getenv("GOBID_MONTHLY_RETRACTION_LIMIT"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_MONTHLY_RETRACTION_LIMIT: must be zero or more")
+		}
+		config.MonthlyRetractionLimit = limit
+	}
+
+	if raw := os.Getenv("GOBID_MAX_CONNECTIONS_PER_USER"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_MAX_CONNECTIONS_PER_USER: must be zero or more")
+		}
+		config.MaxConnectionsPerUser = limit
+	}
+
+	// Without a key the pseudonyms of bidders change on every restart, which
+	// is only acceptable while developing.
+	if raw := os.Getenv("GOBID_BIDDER_HANDLE_KEY"); raw != "" {
+		config.BidderHandleKey = []byte(raw)
+	} else if os.Getenv("GOBID_ENV") != "development" {
+		return services.AuctionConfig{}, fmt.Errorf("GOBID_BIDDER_HANDLE_KEY: required unless GOBID_ENV is development")
+	} else {
+		config.BidderHandleKey = make([]byte, 32)
+		if _, err := rand.Read(config.BidderHandleKey); err != nil {
+			return services.AuctionConfig{}, fmt.Errorf("GOBID_BIDDER_HANDLE_KEY: %w", err)
+		}
+	}
+
+	return config, nil
+}
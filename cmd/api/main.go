@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"encoding/gob"
+	"expvar"
 	"fmt"
 	"gobid/internal/api"
 	"gobid/internal/services"
+	"log/slog"
 	"net/http"
 	"os"
 	"time"
@@ -46,6 +48,11 @@ func main() {
 		panic(err)
 	}
 
+	auctionConfig, err := loadAuctionConfig()
+	if err != nil {
+		panic(err)
+	}
+
 	s := scs.New()
 	s.Store = pgxstore.New(pool)
 	s.Lifetime = 24 * time.Hour
@@ -53,23 +60,77 @@ func main() {
 	s.Cookie.SameSite = http.SameSiteLaxMode
 
 	api := api.Api{
-		Router:         chi.NewMux(),
-		UserService:    services.NewUserService(pool),
-		ProductService: services.NewProductsService(pool),
-		BidsService:    services.NewBidsService(pool),
-		Sessions:       s,
+		Router:            chi.NewMux(),
+		UserService:       services.NewUserService(pool),
+		ProductService:    services.NewProductsService(pool),
+		BidsService:       services.NewBidsService(pool, auctionConfig),
+		SaleEventsService: services.NewSaleEventsService(pool),
+		Sessions:          s,
 		WsUpgrader: websocket.Upgrader{
-			CheckOrigin: func(r *http.Request) bool { return true },
+			CheckOrigin:  func(r *http.Request) bool { return true },
+			Subprotocols: services.Subprotocols,
 		},
 		AuctionLobby: services.AuctionLobby{
 			Rooms: make(map[uuid.UUID]*services.AuctionRoom),
 		},
 	}
 
+	if err := restoreAuctionRooms(ctx, &api); err != nil {
+		panic(err)
+	}
+
 	api.BindRoutes()
 
+	// Runtime metrics, such as the messages rooms dropped for slow clients,
+	// are only served on an internal address of their own.
+	if addr := os.Getenv("GOBID_DEBUG_ADDR"); addr != "" {
+		debug := http.NewServeMux()
+		debug.Handle("/debug/vars", expvar.Handler())
+		go func() {
+			if err := http.ListenAndServe(addr, debug); err != nil {
+				slog.Error("Debug server stopped", "error", err)
+			}
+		}()
+	}
+
 	fmt.Println("Server is running on port 3080")
 	if err := http.ListenAndServe("localhost:3080", api.Router); err != nil {
 		panic(err)
 	}
 }
+
+// restoreAuctionRooms rebuilds an AuctionRoom for every product whose auction
+// is still running, so bidders can reconnect after a restart. Auctions that
+// have not opened yet are scheduled again.
+func restoreAuctionRooms(ctx context.Context, a *api.Api) error {
+	products, err := a.ProductService.ListOpenProducts(ctx)
+	if err != nil {
+		return err
+	}
+
+	restored := 0
+	for _, product := range products {
+		// Auctions that ended while the server was down are settled right
+		// away instead of getting a room nobody can bid in. One that can
+		// not be settled stays open and is tried again on the next start,
+		// it must not keep the API down.
+		if !product.AuctionEnd.After(time.Now()) {
+			result, err := a.BidsService.SettleAuction(ctx, product.ID)
+			if err != nil {
+				slog.Error("Failed to settle expired auction", "auctionId", product.ID, "error", err)
+				continue
+			}
+			if err := a.BidsService.FlagShillBidding(ctx, result); err != nil {
+				slog.Error("Failed to flag shill bidding", "auctionId", product.ID, "error", err)
+			}
+			continue
+		}
+
+		a.AuctionLobby.Open(product, a.BidsService)
+		restored++
+	}
+
+	slog.Info("Auctions restored", "count", restored)
+
+	return nil
+}
@@ -9,11 +9,12 @@ import (
 )
 
 type Api struct {
-	Router         *chi.Mux
-	UserService    services.UserService
-	ProductService services.ProductsService
-	BidsService    services.BidsService
-	Sessions       *scs.SessionManager
-	WsUpgrader     websocket.Upgrader
-	AuctionLobby   services.AuctionLobby
+	Router            *chi.Mux
+	UserService       services.UserService
+	ProductService    services.ProductsService
+	BidsService       services.BidsService
+	SaleEventsService services.SaleEventsService
+	Sessions          *scs.SessionManager
+	WsUpgrader        websocket.Upgrader
+	AuctionLobby      services.AuctionLobby
 }
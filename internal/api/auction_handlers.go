@@ -4,7 +4,10 @@ import (
 	"errors"
 	"gobid/internal/jsonutils"
 	"gobid/internal/services"
+	"gobid/internal/usecase/bid"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
@@ -22,7 +25,7 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	_, err = api.ProductService.GetProductByID(r.Context(), productID)
+	product, err := api.ProductService.GetProductByID(r.Context(), productID)
 	if err != nil {
 		if errors.Is(err, services.ErrProductNotFound) {
 			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
@@ -51,9 +54,34 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 	room, ok := api.AuctionLobby.Rooms[productID]
 	api.AuctionLobby.Unlock()
 
+	if !ok {
+		message := "the auction has ended"
+		if product.AuctionStart.After(time.Now()) {
+			message = "the auction has not started yet"
+		}
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": message,
+		})
+		return
+	}
+
+	// A reconnecting client passes the sequence number of the last event it
+	// received to catch up on the ones it missed.
+	var since uint64
+	if rawSince := r.URL.Query().Get("since"); rawSince != "" {
+		since, err = strconv.ParseUint(rawSince, 10, 64)
+		if err != nil {
+			jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+				"message": "invalid since - must be a sequence number",
+			})
+			return
+		}
+	}
+
+	policy, ok := slowClientPolicy(r)
 	if !ok {
 		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
-			"message": "the auction has ended",
+			"message": "invalid backpressure - must be disconnect or coalesce",
 		})
 		return
 	}
@@ -68,10 +96,425 @@ func (api *Api) handleSubscribeUserToAuction(w http.ResponseWriter, r *http.Requ
 	}
 
 	client := services.NewClient(room, conn, userId)
+	client.Since = since
+	client.Policy = policy
+
+	select {
+	case room.Register <- client:
+	case <-room.Context.Done():
+		conn.Close()
+		return
+	}
 
-	room.Register <- client
 	go client.ReadEventLoop()
 	go client.WriteEventLoop()
-	for {
+}
+
+// slowClientPolicy reads how a client wants to be treated when it falls
+// behind from the backpressure query parameter, disconnecting it by default.
+func slowClientPolicy(r *http.Request) (services.SlowClientPolicy, bool) {
+	switch policy := services.SlowClientPolicy(r.URL.Query().Get("backpressure")); policy {
+	case "":
+		return services.DisconnectSlowClients, true
+	case services.DisconnectSlowClients, services.CoalesceSlowClients:
+		return policy, true
+	}
+	return "", false
+}
+
+func (api *Api) handlePlaceBid(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[bid.PlaceBidReq](r)
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productID]
+	api.AuctionLobby.Unlock()
+
+	if !ok {
+		product, err := api.ProductService.GetProductByID(r.Context(), productID)
+		switch {
+		case errors.Is(err, services.ErrProductNotFound):
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"code":    "product_not_found",
+				"message": "product not found",
+			})
+		case err != nil:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"message": "unexpected error",
+			})
+		case product.AuctionStart.After(time.Now()):
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"code":    "auction_not_open",
+				"message": services.ErrAuctionNotOpen.Error(),
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"code":    "auction_closed",
+				"message": services.ErrAuctionClosed.Error(),
+			})
+		}
+		return
+	}
+
+	answer, err := room.Submit(r.Context(), services.Message{
+		Kind:     services.PlaceBid,
+		UserID:   userId,
+		Amount:   data.Amount,
+		Quantity: data.Quantity,
+	})
+	if err != nil {
+		if errors.Is(err, services.ErrAuctionClosed) {
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"code":    "auction_closed",
+				"message": "the auction has ended",
+			})
+			return
+		}
+		jsonutils.EncodeJson(w, r, http.StatusServiceUnavailable, map[string]any{
+			"message": "the auction is busy, try again later",
+		})
+		return
 	}
+
+	if answer.Kind != services.SuccessfullyPlacedBid {
+		encodeBidError(w, r, answer.Err())
+		return
+	}
+
+	response := map[string]any{
+		"message": answer.Message,
+		"amount":  answer.Amount,
+	}
+	if answer.ClearingPrice != nil {
+		response["quantity"] = answer.Quantity
+		response["clearing_price"] = answer.ClearingPrice
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, response)
+}
+
+// encodeBidError answers a refused bid or buy-now with a status and a
+// stable code clients can act on.
+func encodeBidError(w http.ResponseWriter, r *http.Request, err error) {
+	code := services.ErrorCode(err)
+	body := map[string]any{
+		"code":    code,
+		"message": err.Error(),
+	}
+
+	var tooLow *services.BidTooLowError
+	var tooHigh *services.BidTooHighError
+	if errors.As(err, &tooLow) {
+		body["minimum"] = tooLow.Minimum
+	} else if errors.As(err, &tooHigh) {
+		body["maximum"] = tooHigh.Maximum
+	}
+
+	switch code {
+	case "bid_too_low", "bid_too_high", "invalid_quantity":
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, body)
+	case "self_bid":
+		jsonutils.EncodeJson(w, r, http.StatusForbidden, body)
+	case "auction_closed", "auction_not_open", "wrong_auction_type", "sealed_bid_placed", "buy_now_unavailable":
+		jsonutils.EncodeJson(w, r, http.StatusConflict, body)
+	default:
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+	}
+}
+
+func (api *Api) handleBuyNow(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productID]
+	api.AuctionLobby.Unlock()
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+			"message": "no running auction for this product",
+		})
+		return
+	}
+
+	answer, err := room.Submit(r.Context(), services.Message{Kind: services.BuyNow, UserID: userId})
+	if err != nil {
+		if errors.Is(err, services.ErrAuctionClosed) {
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"code":    "auction_closed",
+				"message": "the auction has ended",
+			})
+			return
+		}
+		jsonutils.EncodeJson(w, r, http.StatusServiceUnavailable, map[string]any{
+			"message": "the auction is busy, try again later",
+		})
+		return
+	}
+
+	if answer.Kind != services.SuccessfullyBoughtNow {
+		encodeBidError(w, r, answer.Err())
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"message": answer.Message,
+		"amount":  answer.Amount,
+	})
+}
+
+func (api *Api) handleRetractBid(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productID]
+	api.AuctionLobby.Unlock()
+
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+			"message": "no running auction for this product",
+		})
+		return
+	}
+
+	answer, err := room.Submit(r.Context(), services.Message{Kind: services.RetractBid, UserID: userId})
+	if err != nil {
+		if errors.Is(err, services.ErrAuctionClosed) {
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"message": "the auction has ended",
+			})
+			return
+		}
+		jsonutils.EncodeJson(w, r, http.StatusServiceUnavailable, map[string]any{
+			"message": "the auction is busy, try again later",
+		})
+		return
+	}
+
+	if answer.Kind != services.SuccessfullyRetractedBid {
+		jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+			"message": answer.Message,
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"message":       answer.Message,
+		"current_price": answer.Amount,
+	})
+}
+
+func (api *Api) handlePlacePreBid(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	data, problems, err := jsonutils.DecodeValidJson[bid.PlaceBidReq](r)
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	preBid, err := api.BidsService.PlacePreBid(r.Context(), productID, userId, data.Amount)
+	if err != nil {
+		var tooLow *services.BidTooLowError
+		switch {
+		case errors.Is(err, services.ErrProductNotFound):
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product not found",
+			})
+		case errors.As(err, &tooLow):
+			jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, map[string]any{
+				"message": tooLow.Error(),
+				"minimum": tooLow.Minimum,
+			})
+		case errors.Is(err, services.ErrSelfBid):
+			jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrAuctionClosed), errors.Is(err, services.ErrAuctionAlreadyOpen), errors.Is(err, services.ErrWrongAuctionType):
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"message": err.Error(),
+			})
+		default:
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"message": "unexpected error",
+			})
+		}
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"message": "Your pre-bid was registered, it will be applied when the auction opens",
+		"amount":  preBid.Amount,
+	})
+}
+
+func (api *Api) handleCancelAuction(w http.ResponseWriter, r *http.Request) {
+	api.handleSellerRequest(w, r, services.CancelAuction, services.SuccessfullyCancelledAuction)
+}
+
+func (api *Api) handleCloseAuctionEarly(w http.ResponseWriter, r *http.Request) {
+	api.handleSellerRequest(w, r, services.CloseAuctionEarly, services.SuccessfullyClosedAuction)
+}
+
+// handleSellerRequest hands a request of the product seller to the auction
+// room, which shuts down once it is accepted. Auctions that have not opened
+// yet have no room, they can only be cancelled.
+func (api *Api) handleSellerRequest(w http.ResponseWriter, r *http.Request, kind, successKind services.MessageKind) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	product, err := api.ProductService.GetProductByID(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product not found",
+			})
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	if product.SellerID != userId {
+		jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+			"message": services.ErrNotProductSeller.Error(),
+		})
+		return
+	}
+
+	api.AuctionLobby.Lock()
+	room, ok := api.AuctionLobby.Rooms[productID]
+	api.AuctionLobby.Unlock()
+
+	if !ok {
+		if kind != services.CancelAuction || product.ClosedAt.Valid {
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"message": "no running auction for this product",
+			})
+			return
+		}
+
+		if _, err := api.BidsService.CancelAuction(r.Context(), productID, userId); err != nil {
+			if errors.Is(err, services.ErrAuctionClosed) || errors.Is(err, services.ErrCancelNotAllowed) {
+				jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+					"message": err.Error(),
+				})
+				return
+			}
+			jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+				"message": "unexpected error",
+			})
+			return
+		}
+		api.AuctionLobby.Unschedule(productID)
+
+		jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+			"message": "Your auction was cancelled",
+		})
+		return
+	}
+
+	answer, err := room.Submit(r.Context(), services.Message{Kind: kind, UserID: userId})
+	if err != nil {
+		if errors.Is(err, services.ErrAuctionClosed) {
+			jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+				"message": "the auction has ended",
+			})
+			return
+		}
+		jsonutils.EncodeJson(w, r, http.StatusServiceUnavailable, map[string]any{
+			"message": "the auction is busy, try again later",
+		})
+		return
+	}
+
+	if answer.Kind != successKind {
+		jsonutils.EncodeJson(w, r, http.StatusConflict, map[string]any{
+			"message": answer.Message,
+		})
+		return
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, map[string]any{
+		"message": answer.Message,
+		"amount":  answer.Amount,
+	})
 }
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"gobid/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEncodeBidError(t *testing.T) {
+	tests := []struct {
+		err      error
+		wantCode string
+		status   int
+	}{
+		{err: &services.BidTooLowError{Minimum: 10_00}, wantCode: "bid_too_low", status: http.StatusUnprocessableEntity},
+		{err: services.ErrSelfBid, wantCode: "self_bid", status: http.StatusForbidden},
+		{err: services.ErrBuyNowUnavailable, wantCode: "buy_now_unavailable", status: http.StatusConflict},
+		{err: services.ErrAuctionClosed, wantCode: "auction_closed", status: http.StatusConflict},
+		{err: services.ErrWrongAuctionType, wantCode: "wrong_auction_type", status: http.StatusConflict},
+		{err: errors.New("connection reset"), status: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		encodeBidError(w, httptest.NewRequest(http.MethodPost, "/", nil), tt.err)
+
+		var body map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if code, _ := body["code"].(string); w.Code != tt.status || code != tt.wantCode {
+			t.Errorf("%v answered %d with code %q, want %d with %q", tt.err, w.Code, code, tt.status, tt.wantCode)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package api
 
 import (
-	"context"
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"gobid/internal/jsonutils"
+	"gobid/internal/money"
 	"gobid/internal/services"
+	"gobid/internal/store/pgstore"
 	"gobid/internal/usecase/product"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
@@ -26,14 +31,37 @@ func (api *Api) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	productId, err := api.ProductService.CreateProduct(
-		r.Context(),
-		userID,
-		data.ProductName,
-		data.Description,
-		data.Baseprice,
-		data.AuctionEnd,
-	)
+	currency := data.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	var auctionStart time.Time
+	if data.AuctionStart != nil {
+		auctionStart = *data.AuctionStart
+	}
+
+	auctionType := data.AuctionType
+	if auctionType == "" {
+		auctionType = pgstore.AuctionTypeEnglish
+	}
+
+	createdProduct, err := api.ProductService.CreateProduct(r.Context(), userID, services.NewProduct{
+		ProductName:       data.ProductName,
+		Description:       data.Description,
+		Baseprice:         data.Baseprice,
+		Currency:          currency,
+		AuctionEnd:        data.AuctionEnd,
+		AuctionStart:      auctionStart,
+		AuctionType:       auctionType,
+		BidIncrements:     data.BidIncrements,
+		ReservePrice:      data.ReservePrice,
+		BuyNowPrice:       data.BuyNowPrice,
+		Quantity:          data.Quantity,
+		FloorPrice:        data.FloorPrice,
+		PriceDecrement:    data.PriceDecrement,
+		DecrementInterval: time.Duration(data.DecrementIntervalSeconds) * time.Second,
+	})
 	if err != nil {
 		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
 			"error": "failed to create product auction try again later",
@@ -41,17 +69,157 @@ func (api *Api) handleCreateProduct(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx, _ := context.WithDeadline(context.Background(), data.AuctionEnd)
-	auctionRoom := services.NewAuctionRoom(ctx, productId, api.BidsService)
+	api.AuctionLobby.Open(createdProduct, api.BidsService)
 
-	go auctionRoom.Run()
-
-	api.AuctionLobby.Lock()
-	api.AuctionLobby.Rooms[productId] = auctionRoom
-	api.AuctionLobby.Unlock()
+	message := "Auction has started with success"
+	if createdProduct.AuctionStart.After(time.Now()) {
+		message = "Auction has been scheduled with success"
+	}
 
 	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
-		"message":    "Auction has started with success",
-		"product_id": productId,
+		"message":       message,
+		"product_id":    createdProduct.ID,
+		"auction_start": createdProduct.AuctionStart,
 	})
 }
+
+func (api *Api) handleGetProduct(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	product, err := api.ProductService.GetProductByID(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product not found",
+			})
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	state, err := api.BidsService.AuctionState(r.Context(), product)
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	now := time.Now()
+	response := map[string]any{
+		"id":                     product.ID,
+		"seller_id":              product.SellerID,
+		"product_name":           product.ProductName,
+		"description":            product.Description,
+		"auction_type":           product.AuctionType,
+		"currency":               product.Currency,
+		"baseprice":              product.Baseprice,
+		"quantity":               product.Quantity,
+		"auction_start":          product.AuctionStart,
+		"auction_end":            product.AuctionEnd,
+		"status":                 state.Status,
+		"current_price":          state.CurrentPrice,
+		"next_bid":               state.NextBid,
+		"bid_count":              state.BidCount,
+		"reserve_met":            state.ReserveMet,
+		"buy_now_price":          product.BuyNowPrice,
+		"time_remaining_seconds": max(int64(product.AuctionEnd.Sub(now)/time.Second), 0),
+		"server_time":            now,
+	}
+	if product.SaleEventID.Valid {
+		response["sale_event_id"] = product.SaleEventID.UUID
+		response["lot_number"] = product.LotNumber.Int32
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, response)
+}
+
+const (
+	defaultBidsPageSize = 20
+	maxBidsPageSize     = 100
+)
+
+func (api *Api) handleListBids(w http.ResponseWriter, r *http.Request) {
+	productID, err := uuid.Parse(chi.URLParam(r, "product_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid product id - must be a valid uuid",
+		})
+		return
+	}
+
+	limit := defaultBidsPageSize
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxBidsPageSize {
+			jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+				"message": "limit must be between 1 and 100",
+			})
+			return
+		}
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	product, err := api.ProductService.GetProductByID(r.Context(), productID)
+	if err != nil {
+		if errors.Is(err, services.ErrProductNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "product not found",
+			})
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	if services.IsSealed(product.AuctionType) && !product.ClosedAt.Valid {
+		jsonutils.EncodeJson(w, r, http.StatusForbidden, map[string]any{
+			"message": "sealed bids are revealed once the auction is over",
+		})
+		return
+	}
+
+	page, err := api.BidsService.ListBids(r.Context(), productID, r.URL.Query().Get("cursor"), int32(limit))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+				"message": err.Error(),
+			})
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	response := map[string]any{
+		"bids": api.BidsService.PublicBids(productID, page.Bids, userId),
+	}
+	if page.NextCursor != "" {
+		response["next_cursor"] = page.NextCursor
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusOK, response)
+}
@@ -32,7 +32,23 @@ func (api *Api) BindRoutes() {
 				r.Group(func(r chi.Router) {
 					r.Use(api.AuthMiddleware)
 					r.Post("/", api.handleCreateProduct)
+					r.Get("/{product_id}", api.handleGetProduct)
+					r.Get("/{product_id}/bids", api.handleListBids)
 					r.Get("/ws/subscribe/{product_id}", api.handleSubscribeUserToAuction)
+					r.Post("/{product_id}/bids", api.handlePlaceBid)
+					r.Post("/{product_id}/buy-now", api.handleBuyNow)
+					r.Post("/{product_id}/retract-bid", api.handleRetractBid)
+					r.Post("/{product_id}/pre-bids", api.handlePlacePreBid)
+					r.Post("/{product_id}/cancel", api.handleCancelAuction)
+					r.Post("/{product_id}/close", api.handleCloseAuctionEarly)
+				})
+			})
+
+			r.Route("/events", func(r chi.Router) {
+				r.Group(func(r chi.Router) {
+					r.Use(api.AuthMiddleware)
+					r.Post("/", api.handleCreateSaleEvent)
+					r.Get("/ws/subscribe/{event_id}", api.handleSubscribeUserToSaleEvent)
 				})
 			})
 		})
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gobid/internal/jsonutils"
+	"gobid/internal/money"
+	"gobid/internal/services"
+	"gobid/internal/usecase/event"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func (api *Api) handleCreateSaleEvent(w http.ResponseWriter, r *http.Request) {
+	data, problems, err := jsonutils.DecodeValidJson[event.CreateSaleEventReq](r)
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusUnprocessableEntity, problems)
+		return
+	}
+
+	userID, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "unexpected error, try again later",
+		})
+		return
+	}
+
+	currency := data.Currency
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+
+	var auctionStart time.Time
+	if data.AuctionStart != nil {
+		auctionStart = *data.AuctionStart
+	}
+
+	lots := make([]services.NewProduct, 0, len(data.Lots))
+	for _, lot := range data.Lots {
+		lots = append(lots, services.NewProduct{
+			ProductName:   lot.ProductName,
+			Description:   lot.Description,
+			Baseprice:     lot.Baseprice,
+			Currency:      currency,
+			BidIncrements: lot.BidIncrements,
+			ReservePrice:  lot.ReservePrice,
+			BuyNowPrice:   lot.BuyNowPrice,
+		})
+	}
+
+	createdEvent, createdLots, err := api.SaleEventsService.CreateSaleEvent(r.Context(), userID, services.NewSaleEvent{
+		Name:         data.Name,
+		AuctionStart: auctionStart,
+		FirstLotEnd:  data.FirstLotEnd,
+		LotInterval:  time.Duration(data.LotIntervalSeconds) * time.Second,
+		Lots:         lots,
+	})
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"error": "failed to create sale event try again later",
+		})
+		return
+	}
+
+	type createdLot struct {
+		ProductID  uuid.UUID `json:"product_id"`
+		LotNumber  int32     `json:"lot_number"`
+		AuctionEnd time.Time `json:"auction_end"`
+	}
+
+	response := make([]createdLot, 0, len(createdLots))
+	for _, lot := range createdLots {
+		api.AuctionLobby.Open(lot, api.BidsService)
+		response = append(response, createdLot{
+			ProductID:  lot.ID,
+			LotNumber:  lot.LotNumber.Int32,
+			AuctionEnd: lot.AuctionEnd,
+		})
+	}
+
+	jsonutils.EncodeJson(w, r, http.StatusCreated, map[string]any{
+		"message":  "Sale event has been created with success",
+		"event_id": createdEvent.ID,
+		"lots":     response,
+	})
+}
+
+func (api *Api) handleSubscribeUserToSaleEvent(w http.ResponseWriter, r *http.Request) {
+	eventID, err := uuid.Parse(chi.URLParam(r, "event_id"))
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid event id - must be a valid uuid",
+		})
+		return
+	}
+
+	saleEvent, lots, err := api.SaleEventsService.GetSaleEvent(r.Context(), eventID)
+	if err != nil {
+		if errors.Is(err, services.ErrSaleEventNotFound) {
+			jsonutils.EncodeJson(w, r, http.StatusNotFound, map[string]any{
+				"message": "sale event not found",
+			})
+			return
+		}
+
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	userId, ok := api.Sessions.Get(r.Context(), "AuthenticatedUserId").(uuid.UUID)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "unexpected error",
+		})
+		return
+	}
+
+	policy, ok := slowClientPolicy(r)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "invalid backpressure - must be disconnect or coalesce",
+		})
+		return
+	}
+
+	room, ok := api.AuctionLobby.EventRoom(saleEvent, lots, api.BidsService)
+	if !ok {
+		jsonutils.EncodeJson(w, r, http.StatusBadRequest, map[string]any{
+			"message": "the sale event has ended",
+		})
+		return
+	}
+
+	conn, err := api.WsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		jsonutils.EncodeJson(w, r, http.StatusInternalServerError, map[string]any{
+			"message": "could not upgrade connection to a websocket protocol",
+		})
+		return
+	}
+
+	client := services.NewClient(room, conn, userId)
+	client.Policy = policy
+
+	select {
+	case room.Register <- client:
+	case <-room.Context.Done():
+		conn.Close()
+		return
+	}
+
+	go client.ReadEventLoop()
+	go client.WriteEventLoop()
+}
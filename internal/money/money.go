@@ -0,0 +1,116 @@
+package money
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Amount is a monetary value expressed in minor units of its currency, e.g.
+// cents for USD. Every supported currency has two decimal places.
+type Amount int64
+
+const (
+	decimalPlaces = 2
+	minorUnits    = 100
+)
+
+var ErrInvalidAmount = errors.New("amount must be a non-negative number with at most 2 decimal places")
+
+// Parse reads a decimal string such as "10", "10.5" or "10.50" without going
+// through floating point.
+func Parse(s string) (Amount, error) {
+	whole, frac, hasFrac := strings.Cut(s, ".")
+	if whole == "" || !isDigits(whole) || (hasFrac && (frac == "" || len(frac) > decimalPlaces || !isDigits(frac))) {
+		return 0, ErrInvalidAmount
+	}
+
+	for len(frac) < decimalPlaces {
+		frac += "0"
+	}
+	cents, _ := strconv.ParseInt(frac, 10, 64)
+
+	// The cents count towards the bound too, the largest amount being
+	// 92233720368547758.07.
+	units, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || units > (math.MaxInt64-cents)/minorUnits {
+		return 0, ErrInvalidAmount
+	}
+
+	return Amount(units*minorUnits + cents), nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (a Amount) String() string {
+	sign := ""
+	v := int64(a)
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, v/minorUnits, v%minorUnits)
+}
+
+// MarshalJSON encodes the amount as a decimal string, e.g. "10.50", so
+// clients never have to round-trip it through a float.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts either a decimal string or a plain JSON number.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+
+	s := string(data)
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+
+	amount, err := Parse(s)
+	if err != nil {
+		return err
+	}
+
+	*a = amount
+	return nil
+}
+
+// Value stores the amount as its integer number of minor units.
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Currency is an ISO 4217 currency code.
+type Currency string
+
+const (
+	USD Currency = "USD"
+	EUR Currency = "EUR"
+	GBP Currency = "GBP"
+	BRL Currency = "BRL"
+
+	DefaultCurrency = USD
+)
+
+func (c Currency) Valid() bool {
+	switch c {
+	case USD, EUR, GBP, BRL:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: "0", want: 0},
+		{in: "10", want: 10_00},
+		{in: "10.5", want: 10_50},
+		{in: "10.50", want: 10_50},
+		{in: "10.05", want: 10_05},
+		{in: "007.10", want: 7_10},
+		{in: "92233720368547758.07", want: math.MaxInt64},
+		{in: "92233720368547758.08", err: ErrInvalidAmount},
+		{in: "92233720368547758.99", err: ErrInvalidAmount},
+		{in: "92233720368547759", err: ErrInvalidAmount},
+		{in: "99999999999999999999", err: ErrInvalidAmount},
+		{in: "", err: ErrInvalidAmount},
+		{in: ".50", err: ErrInvalidAmount},
+		{in: "10.", err: ErrInvalidAmount},
+		{in: "10.505", err: ErrInvalidAmount},
+		{in: "-10", err: ErrInvalidAmount},
+		{in: "+10", err: ErrInvalidAmount},
+		{in: "1e3", err: ErrInvalidAmount},
+		{in: "10,50", err: ErrInvalidAmount},
+		{in: " 10", err: ErrInvalidAmount},
+		{in: "10.5a", err: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("Parse(%q) = %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestAmountJSON(t *testing.T) {
+	for _, a := range []Amount{0, 5, 10_50, 1_000_000_00, math.MaxInt64} {
+		raw, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got Amount
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatalf("%s does not decode: %v", raw, err)
+		}
+		if got != a {
+			t.Errorf("%d is encoded as %s and decoded as %d", a, raw, got)
+		}
+	}
+
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: `"10.50"`, want: 10_50},
+		{in: `10.5`, want: 10_50},
+		{in: `10`, want: 10_00},
+		{in: `null`, want: 7},
+		{in: `"-1"`, err: ErrInvalidAmount},
+		{in: `10.505`, err: ErrInvalidAmount},
+		{in: `1e3`, err: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		// null leaves the amount as it was.
+		got := Amount(7)
+		err := json.Unmarshal([]byte(tt.in), &got)
+		if !errors.Is(err, tt.err) || (err == nil && got != tt.want) {
+			t.Errorf("decoding %s gave %d, %v, want %d, %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
@@ -3,6 +3,10 @@ package services
 import (
 	"context"
 	"errors"
+	"expvar"
+	"fmt"
+	"gobid/internal/money"
+	"gobid/internal/store/pgstore"
 	"log/slog"
 	"sync"
 	"time"
@@ -27,87 +31,1036 @@ const (
 	//Info
 	NewBidPlaced
 	AuctionFinished
+	AuctionWon
+	AuctionSold
+	AuctionUnsold
+
+	// New kinds are appended below so existing clients keep their numbering.
+
+	//Requests
+	PlaceMaxBid
+
+	//Ok/Success
+	SuccessfullyPlacedMaxBid
+
+	//Requests
+	BuyNow
+
+	//Ok/Success
+	SuccessfullyBoughtNow
+
+	//Errors
+	FailedToBuyNow
+
+	//Info
+	AuctionBoughtNow
+	AuctionExtended
+
+	//Requests
+	AcceptPrice
+
+	//Ok/Success
+	SuccessfullyAcceptedPrice
+
+	//Errors
+	FailedToAcceptPrice
+
+	//Info
+	PriceDropped
+	BidsRevealed
+
+	//Requests
+	CancelAuction
+	CloseAuctionEarly
+
+	//Ok/Success
+	SuccessfullyCancelledAuction
+	SuccessfullyClosedAuction
+
+	//Errors
+	FailedToCloseAuction
+
+	//Info
+	AuctionCancelled
+
+	//Errors
+	FailedToReachLot
+
+	//Info
+	CurrentLotChanged
+	EventFinished
+
+	//Requests
+	RetractBid
+
+	//Ok/Success
+	SuccessfullyRetractedBid
+
+	//Errors
+	FailedToRetractBid
+
+	//Info
+	BidRetracted
+	AuctionSnapshot
+
+	//Errors
+	TooManyConnections
+
+	// messageKinds counts the kinds above, it has to stay last.
+	messageKinds
 )
 
+// closesAuction reports whether k is the last message a client receives
+// before the auction room shuts down.
+func (k MessageKind) closesAuction() bool {
+	return k == AuctionFinished || k == AuctionBoughtNow || k == AuctionCancelled || k == EventFinished
+}
+
 type Message struct {
-	Message string      `json:"message,omitempty"`
-	Amount  float64     `json:"amount,omitempty"`
-	Kind    MessageKind `json:"kind"`
-	UserID  uuid.UUID   `json:"user_id,omitempty"`
+	Message string       `json:"message,omitempty"`
+	Amount  money.Amount `json:"amount,omitempty"`
+	Kind    MessageKind  `json:"kind"`
+	UserID  uuid.UUID    `json:"user_id,omitempty"`
+	// Bidder is the BidderHandle of the bidder a message is about, IsYou
+	// tells whether that is the recipient.
+	Bidder     string     `json:"bidder,omitempty"`
+	IsYou      bool       `json:"is_you,omitempty"`
+	ReserveMet *bool      `json:"reserve_met,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+	// Bids lists every sealed bid once the auction is over.
+	Bids []RevealedBid `json:"bids,omitempty"`
+	// Quantity is the number of units of a multi-unit auction a bid asks for
+	// or won, ClearingPrice its lowest winning price per unit.
+	Quantity      int32         `json:"quantity,omitempty"`
+	ClearingPrice *money.Amount `json:"clearing_price,omitempty"`
+	// ProductID names the lot a message of a sale event is about.
+	ProductID uuid.UUID `json:"product_id,omitempty"`
+	// Snapshot is the state of the auction sent to a client when it joins.
+	Snapshot *Snapshot `json:"snapshot,omitempty"`
+	// Seq numbers the events of a room in the order they happened, see
+	// AuctionRoom.record. Answers to requests have none.
+	Seq uint64 `json:"seq,omitempty"`
+
+	// to is the only user a message relayed to watchers is meant for.
+	to uuid.UUID
+	// bidder is the user behind Bidder, see Message.forUser.
+	bidder uuid.UUID
+	// from is the connection a client request came through.
+	from *Client
+	// requestID is the correlation ID of a ProtocolV2 request, echoed on
+	// its answer.
+	requestID string
+	// reply receives the answer meant for the sender of a message submitted
+	// outside of a websocket connection, see AuctionRoom.Submit.
+	reply chan Message
+	// err is why a request was refused, see Message.Err.
+	err error
+}
+
+// Err returns the error behind a failure answer, so callers of Submit can
+// tell the reasons apart.
+func (m Message) Err() error {
+	return m.err
+}
+
+// forUser is m as userID receives it, recognizing their own bids.
+func (m Message) forUser(userID uuid.UUID) Message {
+	m.IsYou = m.bidder != uuid.Nil && m.bidder == userID
+
+	if len(m.Bids) > 0 {
+		bids := make([]RevealedBid, len(m.Bids))
+		for i, bid := range m.Bids {
+			bid.IsYou = bid.bidderID == userID
+			bids[i] = bid
+		}
+		m.Bids = bids
+	}
+
+	return m
+}
+
+// RevealedBid is a sealed bid disclosed to every client once the auction is
+// over, its bidder hidden behind their BidderHandle.
+type RevealedBid struct {
+	Bidder string       `json:"bidder"`
+	IsYou  bool         `json:"is_you"`
+	Amount money.Amount `json:"amount"`
+
+	bidderID uuid.UUID
+}
+
+// Snapshot is everything a client joining an auction room needs to catch up
+// before live updates arrive.
+type Snapshot struct {
+	ProductID    uuid.UUID           `json:"product_id"`
+	ProductName  string              `json:"product_name"`
+	AuctionType  pgstore.AuctionType `json:"auction_type"`
+	Currency     money.Currency      `json:"currency"`
+	Baseprice    money.Amount        `json:"baseprice"`
+	Quantity     int32               `json:"quantity"`
+	Status       AuctionStatus       `json:"status"`
+	CurrentPrice *money.Amount       `json:"current_price,omitempty"`
+	// Leader is the BidderHandle of the leading bidder, like the bidders of
+	// RecentBids, and LeaderIsYou tells the client whether that is them.
+	Leader      string        `json:"leader,omitempty"`
+	LeaderIsYou bool          `json:"leader_is_you"`
+	NextBid     *money.Amount `json:"next_bid,omitempty"`
+	BidCount    int64         `json:"bid_count"`
+	ReserveMet  *bool         `json:"reserve_met,omitempty"`
+	AuctionEnd  time.Time     `json:"auction_end"`
+	ServerTime  time.Time     `json:"server_time"`
+	// RecentBids are the latest bids, newest first.
+	RecentBids []PublicBid `json:"recent_bids"`
 }
 
 type AuctionLobby struct {
 	sync.Mutex
 	Rooms map[uuid.UUID]*AuctionRoom
+
+	// scheduled holds the timers of auctions that have not opened yet.
+	scheduled map[uuid.UUID]*time.Timer
+	// events holds the rooms of the sale events someone follows, see
+	// AuctionLobby.EventRoom.
+	events map[uuid.UUID]*SaleEventRoom
+	// settling holds the timers of auctions whose room is over but could not
+	// settle them, see AuctionLobby.settle.
+	settling map[uuid.UUID]*time.Timer
+}
+
+// openRetryDelay is how long the lobby waits before trying to open an
+// auction again when its pre-bids could not be applied.
+const openRetryDelay = 30 * time.Second
+
+// settleRetryDelay is how long the lobby first waits before settling an
+// auction its room failed to settle. The wait doubles on every failure, up
+// to maxSettleRetryDelay.
+const (
+	settleRetryDelay    = 5 * time.Second
+	maxSettleRetryDelay = 10 * time.Minute
+)
+
+// Open starts the room of product once its auction opens: right away when
+// its start time has passed, otherwise on a timer.
+func (l *AuctionLobby) Open(product pgstore.Product, bidsService BidsService) {
+	wait := time.Until(product.AuctionStart)
+	if wait <= 0 {
+		l.startRoom(product, bidsService)
+		return
+	}
+
+	slog.Info("Auction has been scheduled", "auctionId", product.ID, "auctionStart", product.AuctionStart)
+	l.schedule(product, bidsService, wait)
+}
+
+func (l *AuctionLobby) schedule(product pgstore.Product, bidsService BidsService, wait time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.scheduled == nil {
+		l.scheduled = make(map[uuid.UUID]*time.Timer)
+	}
+	l.scheduled[product.ID] = time.AfterFunc(wait, func() {
+		l.startRoom(product, bidsService)
+	})
+}
+
+// startRoom applies the pre-bids of product before its room accepts anyone,
+// then runs the room and adds it to the lobby.
+func (l *AuctionLobby) startRoom(product pgstore.Product, bidsService BidsService) {
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+
+	opened, err := bidsService.OpenAuction(ctx, product.ID)
+	if err != nil {
+		if errors.Is(err, ErrAuctionClosed) || errors.Is(err, ErrProductNotFound) {
+			l.Lock()
+			delete(l.scheduled, product.ID)
+			l.Unlock()
+
+			slog.Info("Auction closed before opening", "auctionId", product.ID)
+			return
+		}
+
+		slog.Error("Failed to open auction", "auctionId", product.ID, "error", err)
+		l.schedule(product, bidsService, openRetryDelay)
+		return
+	}
+
+	room := NewAuctionRoom(opened, bidsService)
+
+	l.Lock()
+	delete(l.scheduled, product.ID)
+	l.Rooms[product.ID] = room
+	if event, ok := l.events[product.SaleEventID.UUID]; ok && product.SaleEventID.Valid {
+		go event.follow(room)
+	}
+	l.Unlock()
+
+	go func() {
+		room.Run()
+		l.remove(room)
+		if room.unsettled {
+			l.settleLater(product.ID, bidsService, settleRetryDelay)
+		}
+	}()
+}
+
+func (l *AuctionLobby) settleLater(productID uuid.UUID, bidsService BidsService, wait time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.settling == nil {
+		l.settling = make(map[uuid.UUID]*time.Timer)
+	}
+	l.settling[productID] = time.AfterFunc(wait, func() {
+		l.settle(productID, bidsService, wait)
+	})
+}
+
+// settle closes an auction whose room is over without having settled it,
+// trying again later, after twice the last wait, when it still can not.
+func (l *AuctionLobby) settle(productID uuid.UUID, bidsService BidsService, wait time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+
+	result, err := bidsService.SettleAuction(ctx, productID)
+	if err != nil && !errors.Is(err, ErrAuctionAlreadySettled) {
+		slog.Error("Failed to settle auction", "auctionId", productID, "error", err)
+		l.settleLater(productID, bidsService, min(2*wait, maxSettleRetryDelay))
+		return
+	}
+
+	l.Lock()
+	delete(l.settling, productID)
+	l.Unlock()
+
+	if err != nil {
+		return
+	}
+	slog.Info("Auction has been settled", "auctionId", productID)
+	if err := bidsService.FlagShillBidding(ctx, result); err != nil {
+		slog.Error("Failed to flag shill bidding", "auctionId", productID, "error", err)
+	}
+}
+
+// remove drops room from the lobby once it has shut down.
+func (l *AuctionLobby) remove(room *AuctionRoom) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.Rooms[room.Id] == room {
+		delete(l.Rooms, room.Id)
+	}
+}
+
+// Unschedule stops the timer of an auction that has not opened yet.
+func (l *AuctionLobby) Unschedule(productID uuid.UUID) {
+	l.Lock()
+	defer l.Unlock()
+
+	if timer, ok := l.scheduled[productID]; ok {
+		timer.Stop()
+		delete(l.scheduled, productID)
+	}
 }
 
 type AuctionRoom struct {
-	Id         uuid.UUID
+	Id uuid.UUID
+	// Product is the auctioned product as it was when the room was created.
+	Product pgstore.Product
+	// Context is cancelled once the auction is over.
 	Context    context.Context
+	AuctionEnd time.Time
 	Broadcast  chan Message
 	Register   chan *Client
 	Unregister chan *Client
-	Clients    map[uuid.UUID]*Client
+	// Clients holds every connection to the room, a user may have several.
+	Clients map[*Client]bool
 
 	BidsService BidsService
+
+	cancel context.CancelFunc
+	// deadline fires at AuctionEnd. Unlike a context deadline it can be
+	// moved when a late bid extends the auction.
+	deadline *time.Timer
+	// result is set when the auction was closed before its deadline and
+	// has already been settled.
+	result *AuctionResult
+	// unsettled is set when the room could not settle the auction once it
+	// was over, the lobby then keeps trying.
+	unsettled bool
+
+	// watchers follow every announcement of the room without being one of
+	// its clients, see AuctionRoom.Watch.
+	watch    chan watcher
+	watchers []watcher
+
+	// seq is the sequence number of the latest event and history holds the
+	// latest events so reconnecting clients can catch up, see
+	// AuctionRoom.record.
+	seq     uint64
+	history []Message
+	// view caches the state of the auction for snapshots, see
+	// AuctionRoom.currentView.
+	view *auctionView
+}
+
+// historySize is how many events a room keeps for clients resuming their
+// stream.
+const historySize = 256
+
+type watcher struct {
+	updates chan<- Message
+	done    <-chan struct{}
+}
+
+// Watch relays every announcement of the room to updates until done is
+// closed, and closes updates once it stops: when done is closed, when the
+// room is over, or when updates is full because the watcher fell behind. It
+// reports false, leaving updates open, when the auction is already over.
+func (r *AuctionRoom) Watch(updates chan<- Message, done <-chan struct{}) bool {
+	select {
+	case r.watch <- watcher{updates: updates, done: done}:
+		return true
+	case <-r.Context.Done():
+		return false
+	case <-done:
+		return false
+	}
+}
+
+func (r *AuctionRoom) channels() (chan<- Message, chan<- *Client, <-chan struct{}) {
+	return r.Broadcast, r.Unregister, r.Context.Done()
+}
+
+var ErrRoomIsBusy = errors.New("the auction room did not answer in time")
+
+// Submit hands m to the room event loop on behalf of a user that is not
+// connected through a websocket and waits for the answer meant for them.
+func (r *AuctionRoom) Submit(ctx context.Context, m Message) (Message, error) {
+	// The answer goes back through reply. A connection m came from, such as
+	// one of a sale event, is not a client of this room.
+	m.from = nil
+	m.reply = make(chan Message, 1)
+
+	select {
+	case r.Broadcast <- m:
+	case <-r.Context.Done():
+		return Message{}, ErrAuctionClosed
+	case <-ctx.Done():
+		return Message{}, ErrRoomIsBusy
+	}
+
+	select {
+	case answer := <-m.reply:
+		return answer, nil
+	case <-ctx.Done():
+		return Message{}, ErrRoomIsBusy
+	}
+}
+
+// reply sends answer to whoever sent m, either its connected client or the
+// caller waiting in Submit.
+func (r *AuctionRoom) reply(m Message, answer Message) {
+	answer.requestID = m.requestID
+	if m.reply != nil {
+		m.reply <- answer
+		return
+	}
+	r.sendToSessions(m.UserID, answer)
+}
+
+// sendTo notifies userID of m, on this room or through its watchers.
+func (r *AuctionRoom) sendTo(userID uuid.UUID, m Message) {
+	m.to = userID
+	m = r.record(m)
+
+	r.sendToSessions(userID, m)
+
+	r.notifyWatchers(m)
+}
+
+// announce sends m to every client of the room except skip, and to every
+// watcher such as a sale event following the room.
+func (r *AuctionRoom) announce(m Message, skip uuid.UUID) {
+	m = r.record(m)
+
+	for client := range r.Clients {
+		if client.UserID != skip {
+			r.push(client, m)
+		}
+	}
+
+	r.notifyWatchers(m)
+}
+
+// sendToSessions sends m to every connection of userID.
+func (r *AuctionRoom) sendToSessions(userID uuid.UUID, m Message) {
+	for client := range r.Clients {
+		if client.UserID == userID {
+			r.push(client, m)
+		}
+	}
+}
+
+// push sends m to c without blocking the room, dropping c when it can not
+// keep up, see Client.push.
+func (r *AuctionRoom) push(c *Client, m Message) {
+	if !c.push(m, r.resync) {
+		delete(r.Clients, c)
+	}
+}
+
+// resync brings a client that skipped messages up to date with a snapshot
+// of the auction, which already includes the event m if it is one. Clients
+// catching up between two events share the same view of the auction.
+func (r *AuctionRoom) resync(c *Client, m Message) []Message {
+	snapshot, err := r.snapshot(c.UserID)
+	if err != nil {
+		slog.Error("Failed to take auction snapshot", "auctionId", r.Id, "error", err)
+		return []Message{m}
+	}
+
+	resync := []Message{{Kind: AuctionSnapshot, Message: "Current state of the auction", Snapshot: &snapshot, Seq: r.seq}}
+	if m.Seq == 0 {
+		resync = append(resync, m)
+	}
+	return resync
+}
+
+// admit adds c to clients unless its user already has limit connections, in
+// which case c is told so and its connection closed. A limit of zero admits
+// everyone.
+func admit(clients map[*Client]bool, c *Client, limit int) bool {
+	if limit > 0 {
+		sessions := 0
+		for client := range clients {
+			if client.UserID == c.UserID {
+				sessions++
+			}
+		}
+		if sessions >= limit {
+			slog.Info("Too many connections", "Client", c, "limit", limit)
+			c.Send <- Message{Kind: TooManyConnections, Message: fmt.Sprintf("you can not open more than %d connections", limit), UserID: c.UserID}
+			return false
+		}
+	}
+
+	clients[c] = true
+	return true
+}
+
+// record numbers the event m and keeps it in the room history.
+func (r *AuctionRoom) record(m Message) Message {
+	r.view = nil
+	r.seq++
+	m.Seq = r.seq
+
+	if len(r.history) == historySize {
+		copy(r.history, r.history[1:])
+		r.history = r.history[:historySize-1]
+	}
+	r.history = append(r.history, m)
+
+	return m
+}
+
+// replay sends c the events it missed since its last sequence number. It
+// reports false when some of them are no longer in the history, the client
+// has to start over from a snapshot then.
+func (r *AuctionRoom) replay(c *Client, since uint64) bool {
+	if since > r.seq || (len(r.history) > 0 && r.history[0].Seq > since+1) || (len(r.history) == 0 && since != r.seq) {
+		return false
+	}
+
+	for _, m := range r.history {
+		if m.Seq > since && (m.to == uuid.Nil || m.to == c.UserID) {
+			r.push(c, m)
+		}
+	}
+	return true
+}
+
+// notifyWatchers hands m to every watcher without blocking the room, the
+// same way Client.push does for clients. A watcher with no room left for m
+// is dropped.
+func (r *AuctionRoom) notifyWatchers(m Message) {
+	m.ProductID = r.Id
+	watchers := r.watchers[:0]
+	for _, w := range r.watchers {
+		select {
+		case <-w.done:
+			close(w.updates)
+			continue
+		default:
+		}
+
+		select {
+		case w.updates <- m:
+			watchers = append(watchers, w)
+		default:
+			slog.Info("Dropping slow watcher", "auctionId", r.Id)
+			droppedMessages.Add("watcher", 1)
+			slowWatchersDropped.Add(1)
+			close(w.updates)
+		}
+	}
+	r.watchers = watchers
 }
 
 func (r *AuctionRoom) registerClient(c *Client) {
+	if !admit(r.Clients, c, r.BidsService.config.MaxConnectionsPerUser) {
+		return
+	}
 	slog.Info("New user connected", "Client", c)
-	r.Clients[c.UserID] = c
+
+	if c.Since > 0 && r.replay(c, c.Since) {
+		return
+	}
+
+	// Every bid goes through this event loop, so nothing can happen between
+	// the snapshot and the next live update. The snapshot carries the
+	// sequence number of the latest event it includes.
+	snapshot, err := r.snapshot(c.UserID)
+	if err != nil {
+		slog.Error("Failed to take auction snapshot", "auctionId", r.Id, "error", err)
+		return
+	}
+	r.push(c, Message{Kind: AuctionSnapshot, Message: "Current state of the auction", Snapshot: &snapshot, Seq: r.seq})
+}
+
+// snapshotBids is how many of the latest bids a snapshot carries.
+const snapshotBids = 10
+
+// auctionView is the state of the auction shared by every snapshot until
+// something happens in the room, see AuctionRoom.currentView.
+type auctionView struct {
+	state AuctionState
+	// bids are the latest bids, nil for sealed auctions.
+	bids []pgstore.Bid
+}
+
+// currentView reads the state of the auction once and keeps it until the
+// next event or request, so clients joining or catching up together cost a
+// single trip to the database instead of holding up the room with one each.
+func (r *AuctionRoom) currentView() (*auctionView, error) {
+	if r.view != nil {
+		return r.view, nil
+	}
+
+	product := r.Product
+	product.AuctionEnd = r.AuctionEnd
+
+	state, err := r.BidsService.AuctionState(r.Context, product)
+	if err != nil {
+		return nil, err
+	}
+	view := &auctionView{state: state}
+
+	if !IsSealed(product.AuctionType) {
+		page, err := r.BidsService.ListBids(r.Context, r.Id, "", snapshotBids)
+		if err != nil {
+			return nil, err
+		}
+		view.bids = page.Bids
+	}
+
+	r.view = view
+	return view, nil
+}
+
+// snapshot reads the current state of the auction as seen by userID.
+func (r *AuctionRoom) snapshot(userID uuid.UUID) (Snapshot, error) {
+	view, err := r.currentView()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	state := view.state
+	product := r.Product
+
+	snapshot := Snapshot{
+		ProductID:    product.ID,
+		ProductName:  product.ProductName,
+		AuctionType:  product.AuctionType,
+		Currency:     product.Currency,
+		Baseprice:    product.Baseprice,
+		Quantity:     product.Quantity,
+		Status:       state.Status,
+		CurrentPrice: state.CurrentPrice,
+		NextBid:      state.NextBid,
+		BidCount:     state.BidCount,
+		ReserveMet:   state.ReserveMet,
+		AuctionEnd:   r.AuctionEnd,
+		ServerTime:   time.Now(),
+		RecentBids:   r.BidsService.PublicBids(r.Id, view.bids, userID),
+	}
+	if state.HasLeading {
+		snapshot.Leader = r.BidsService.BidderHandle(r.Id, state.LeadingBid.BidderID)
+		snapshot.LeaderIsYou = state.LeadingBid.BidderID == userID
+	}
+
+	return snapshot, nil
 }
 
 func (r *AuctionRoom) unregisterClient(c *Client) {
 	slog.Info("User disconnected", "Client", c)
-	delete(r.Clients, c.UserID)
+	delete(r.Clients, c)
 }
 
 func (r *AuctionRoom) brodcastMessage(m Message) {
+	// A connection turned away by admit may still have sent something.
+	if m.from != nil && !r.Clients[m.from] {
+		return
+	}
 	slog.Info("New message received", "RoomID", r.Id, "message", m, "user_id", m.UserID)
+	// Requests may change the auction without announcing it, such as
+	// sealed bids.
+	r.view = nil
 	switch m.Kind {
 	case PlaceBid:
-		bid, error := r.BidsService.PlaceBid(r.Context, r.Id, m.UserID, m.Amount)
-		if error != nil {
-			if errors.Is(error, ErrBidIsTooLow) {
-				if client, ok := r.Clients[m.UserID]; ok {
-					client.Send <- Message{Kind: FailedToPlaceBid, Message: ErrBidIsTooLow.Error(), UserID: m.UserID}
-				}
-				return
-			}
+		if IsSealed(r.Product.AuctionType) {
+			r.acknowledgeSealedBid(m)
+			return
 		}
+		if IsMultiUnit(r.Product.AuctionType) {
+			outcome, err := r.BidsService.PlaceMultiUnitBid(r.Context, r.Id, m.UserID, m.Amount, max(m.Quantity, 1))
+			r.announceBid(m, outcome, err, SuccessfullyPlacedBid, "Your bid was placed with success")
+			return
+		}
+		outcome, err := r.BidsService.PlaceBid(r.Context, r.Id, m.UserID, m.Amount)
+		r.announceBid(m, outcome, err, SuccessfullyPlacedBid, "Your bid was placed with success")
+	case PlaceMaxBid:
+		outcome, err := r.BidsService.PlaceMaxBid(r.Context, r.Id, m.UserID, m.Amount)
+		r.announceBid(m, outcome, err, SuccessfullyPlacedMaxBid, "Your maximum bid was registered")
+	case BuyNow:
+		result, err := r.BidsService.BuyNow(r.Context, r.Id, m.UserID)
+		r.closeEarly(m, result, err, SuccessfullyBoughtNow, "You bought the product", FailedToBuyNow)
+	case AcceptPrice:
+		result, err := r.BidsService.AcceptPrice(r.Context, r.Id, m.UserID)
+		r.closeEarly(m, result, err, SuccessfullyAcceptedPrice, "You accepted the current price", FailedToAcceptPrice)
+	case CancelAuction:
+		result, err := r.BidsService.CancelAuction(r.Context, r.Id, m.UserID)
+		r.closeEarly(m, result, err, SuccessfullyCancelledAuction, "Your auction was cancelled", FailedToCloseAuction)
+	case CloseAuctionEarly:
+		result, err := r.BidsService.CloseAuctionEarly(r.Context, r.Id, m.UserID)
+		r.closeEarly(m, result, err, SuccessfullyClosedAuction, "Your auction was closed", FailedToCloseAuction)
+	case RetractBid:
+		outcome, err := r.BidsService.RetractBid(r.Context, r.Id, m.UserID)
+		r.announceRetraction(m, outcome, err)
+	case InvalidJSON:
+		if m.from != nil {
+			r.push(m.from, m)
+		}
+	}
+}
 
-		if client, ok := r.Clients[m.UserID]; ok {
-			client.Send <- Message{
-				Kind:    SuccessfullyPlacedBid,
-				Message: "Your bid was placed with success",
-				UserID:  m.UserID,
-			}
+// closeEarly answers a request that ends the auction before its deadline,
+// such as buy-now, accepting a dutch price or the seller calling it off. On
+// success the room deadline is cancelled and the event loop announces the
+// outcome on its way out.
+func (r *AuctionRoom) closeEarly(m Message, result AuctionResult, err error, successKind MessageKind, successMessage string, failedKind MessageKind) {
+	if err != nil {
+		failed := Message{Kind: failedKind, Message: "could not complete your request, try again later", UserID: m.UserID, err: err}
+		if errors.Is(err, ErrBuyNowUnavailable) || errors.Is(err, ErrAuctionClosed) || errors.Is(err, ErrWrongAuctionType) ||
+			errors.Is(err, ErrNotProductSeller) || errors.Is(err, ErrCancelNotAllowed) || errors.Is(err, ErrNoBidToAccept) || errors.Is(err, ErrSelfBid) {
+			failed.Message = err.Error()
+		} else {
+			slog.Error("Failed to close auction early", "RoomID", r.Id, "error", err)
 		}
+		r.reply(m, failed)
+		return
+	}
 
-		for id, client := range r.Clients {
-			newBidMessage := Message{Kind: NewBidPlaced, Message: "A new bid was placed", Amount: bid.BidAmount, UserID: m.UserID}
-			if id == m.UserID {
-				continue
-			}
-			client.Send <- newBidMessage
+	r.reply(m, Message{
+		Kind:    successKind,
+		Message: successMessage,
+		Amount:  result.Sale.FinalPrice,
+		UserID:  m.UserID,
+	})
+
+	r.result = &result
+	r.cancel()
+}
+
+// rejectBid tells the bidder why their bid was refused.
+func (r *AuctionRoom) rejectBid(m Message, err error) {
+	failed := Message{Kind: FailedToPlaceBid, Message: "could not place your bid, try again later", UserID: m.UserID, err: err}
+
+	var tooLow *BidTooLowError
+	var tooHigh *BidTooHighError
+	if errors.As(err, &tooLow) {
+		failed.Message = tooLow.Error()
+		failed.Amount = tooLow.Minimum
+	} else if errors.As(err, &tooHigh) {
+		failed.Message = tooHigh.Error()
+		failed.Amount = tooHigh.Maximum
+	} else if errors.Is(err, ErrBidIsTooLow) || errors.Is(err, ErrBidIsTooHigh) || errors.Is(err, ErrAuctionClosed) || errors.Is(err, ErrAuctionNotOpen) || errors.Is(err, ErrWrongAuctionType) || errors.Is(err, ErrSealedBidPlaced) ||
+		errors.Is(err, ErrInvalidQuantity) || errors.Is(err, ErrSelfBid) {
+		failed.Message = err.Error()
+	} else {
+		slog.Error("Failed to place bid", "RoomID", r.Id, "error", err)
+	}
+
+	r.reply(m, failed)
+}
+
+// announceRetraction answers a bid retraction and tells every client the
+// price recalculated from the remaining bids.
+func (r *AuctionRoom) announceRetraction(m Message, outcome RetractionOutcome, err error) {
+	if err != nil {
+		failed := Message{Kind: FailedToRetractBid, Message: "could not retract your bid, try again later", UserID: m.UserID, err: err}
+		if errors.Is(err, ErrNoBidToRetract) || errors.Is(err, ErrRetractionWindowPassed) || errors.Is(err, ErrRetractionTooLate) ||
+			errors.Is(err, ErrRetractionLimitExceeded) || errors.Is(err, ErrAuctionClosed) || errors.Is(err, ErrWrongAuctionType) {
+			failed.Message = err.Error()
+		} else {
+			slog.Error("Failed to retract bid", "RoomID", r.Id, "error", err)
 		}
-	case InvalidJSON:
-		client, ok := r.Clients[m.UserID]
-		if !ok {
-			slog.Info("Client not found", "user_id", m.UserID)
-			return
+		r.reply(m, failed)
+		return
+	}
+
+	r.reply(m, Message{
+		Kind:    SuccessfullyRetractedBid,
+		Message: "Your bid was retracted",
+		Amount:  outcome.Price,
+		UserID:  m.UserID,
+	})
+
+	retracted := Message{
+		Kind:       BidRetracted,
+		Message:    "A bid was retracted, the price was recalculated",
+		Amount:     outcome.Price,
+		ReserveMet: outcome.ReserveMet,
+	}
+	if outcome.HasLeading {
+		retracted.Bidder = r.BidsService.BidderHandle(r.Id, outcome.Leading.BidderID)
+		retracted.bidder = outcome.Leading.BidderID
+	}
+	r.announce(retracted, uuid.Nil)
+}
+
+// acknowledgeSealedBid places a sealed bid. Only the bidder hears about it,
+// every bid is revealed when the auction is over.
+func (r *AuctionRoom) acknowledgeSealedBid(m Message) {
+	bid, err := r.BidsService.PlaceSealedBid(r.Context, r.Id, m.UserID, m.Amount)
+	if err != nil {
+		r.rejectBid(m, err)
+		return
+	}
+
+	r.reply(m, Message{
+		Kind:    SuccessfullyPlacedBid,
+		Message: "Your sealed bid was placed with success",
+		Amount:  bid.BidAmount,
+		UserID:  m.UserID,
+	})
+}
+
+// announceBid answers the bidder and, when the visible price moved, tells
+// every other client about the bid now leading the auction.
+func (r *AuctionRoom) announceBid(m Message, outcome BidOutcome, err error, successKind MessageKind, successMessage string) {
+	if err != nil {
+		r.rejectBid(m, err)
+		return
+	}
+
+	var clearingPrice *money.Amount
+	if IsMultiUnit(r.Product.AuctionType) {
+		clearingPrice = &outcome.ClearingPrice
+	}
+
+	r.reply(m, Message{
+		Kind:          successKind,
+		Message:       successMessage,
+		Amount:        outcome.Leading.BidAmount,
+		UserID:        m.UserID,
+		Quantity:      outcome.Leading.Quantity,
+		ClearingPrice: clearingPrice,
+	})
+
+	if !outcome.Changed {
+		return
+	}
+
+	if outcome.Extended {
+		r.extendAuction(outcome.AuctionEnd)
+	}
+
+	// A bidder who took the lead already knows about it.
+	leaderID := outcome.Leading.BidderID
+	skip := uuid.Nil
+	if m.UserID == leaderID {
+		skip = leaderID
+	}
+
+	r.announce(Message{
+		Kind:          NewBidPlaced,
+		Message:       "A new bid was placed",
+		Amount:        outcome.Leading.BidAmount,
+		Bidder:        r.BidsService.BidderHandle(r.Id, leaderID),
+		bidder:        leaderID,
+		ReserveMet:    outcome.ReserveMet,
+		Quantity:      outcome.Leading.Quantity,
+		ClearingPrice: clearingPrice,
+	}, skip)
+}
+
+// extendAuction moves the deadline of the room to end and tells every client.
+func (r *AuctionRoom) extendAuction(end time.Time) {
+	slog.Info("Auction has been extended", "auctionId", r.Id, "auctionEnd", end)
+	r.AuctionEnd = end
+	r.deadline.Reset(time.Until(end))
+
+	r.announce(Message{Kind: AuctionExtended, Message: "The auction has been extended", EndsAt: &end}, uuid.Nil)
+}
+
+// announcePrice tells every client the current asking price of a dutch auction.
+func (r *AuctionRoom) announcePrice(price money.Amount) {
+	r.announce(Message{Kind: PriceDropped, Message: "The price has dropped", Amount: price}, uuid.Nil)
+}
+
+const settlementTimeout = 30 * time.Second
+
+// finishAuction settles the auction, unless it was already closed early, and
+// lets the winner and the seller know the outcome before every client is
+// told the auction is over.
+func (r *AuctionRoom) finishAuction() {
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+
+	finished := Message{Kind: AuctionFinished, Message: "Auction has been finished"}
+
+	var err error
+	var result AuctionResult
+	if r.result != nil {
+		result = *r.result
+	} else {
+		result, err = r.BidsService.SettleAuction(ctx, r.Id)
+	}
+
+	if err == nil {
+		defer r.flagShillBidding(result)
+	}
+
+	if result.Cancelled {
+		r.announce(Message{Kind: AuctionCancelled, Message: "The auction was cancelled by the seller, every bid has been voided"}, uuid.Nil)
+		return
+	}
+
+	if result.BoughtNow {
+		finished = Message{Kind: AuctionBoughtNow, Message: "The product was bought at its buy-now price"}
+	}
+
+	if len(result.Bids) > 0 {
+		revealed := make([]RevealedBid, 0, len(result.Bids))
+		for _, bid := range result.Bids {
+			revealed = append(revealed, RevealedBid{
+				Bidder:   r.BidsService.BidderHandle(r.Id, bid.BidderID),
+				Amount:   bid.BidAmount,
+				bidderID: bid.BidderID,
+			})
 		}
-		client.Send <- m
+		r.announce(Message{Kind: BidsRevealed, Message: "The sealed bids have been revealed", Bids: revealed}, uuid.Nil)
 	}
+
+	if err != nil {
+		slog.Error("Failed to settle auction", "auctionId", r.Id, "error", err)
+		r.unsettled = !errors.Is(err, ErrAuctionAlreadySettled)
+	} else if len(result.Sales) > 0 {
+		r.announceUnitSales(result, &finished)
+	} else if result.Sold {
+		finished.Amount = result.Sale.FinalPrice
+		winnerID := result.LeadingBid.BidderID
+		r.sendTo(winnerID, Message{
+			Kind:    AuctionWon,
+			Message: "You won the auction",
+			Amount:  result.Sale.FinalPrice,
+			UserID:  winnerID,
+		})
+		sold := Message{
+			Kind:    AuctionSold,
+			Message: "Your product was sold",
+			Amount:  result.Sale.FinalPrice,
+			UserID:  winnerID,
+		}
+		if result.Product.AuctionType == pgstore.AuctionTypeReverse {
+			sold.Message = "Your tender was awarded"
+		}
+		r.sendTo(result.Product.SellerID, sold)
+	} else if result.ReserveNotMet {
+		reserveMet := false
+		finished.ReserveMet = &reserveMet
+		for _, id := range []uuid.UUID{result.Product.SellerID, result.LeadingBid.BidderID} {
+			r.sendTo(id, Message{
+				Kind:       AuctionUnsold,
+				Message:    "The auction ended below the reserve price",
+				Amount:     result.LeadingBid.BidAmount,
+				UserID:     id,
+				ReserveMet: &reserveMet,
+			})
+		}
+	} else {
+		r.sendTo(result.Product.SellerID, Message{
+			Kind:    AuctionUnsold,
+			Message: "Your auction ended without bids",
+			UserID:  result.Product.SellerID,
+		})
+	}
+
+	r.announce(finished, uuid.Nil)
+}
+
+// announceUnitSales tells every winner of a multi-unit auction how many units
+// they won and for how much, and the seller how many units were sold.
+func (r *AuctionRoom) announceUnitSales(result AuctionResult, finished *Message) {
+	finished.Amount = result.ClearingPrice
+	finished.ClearingPrice = &result.ClearingPrice
+
+	var total money.Amount
+	var units int32
+	for _, sale := range result.Sales {
+		total += sale.FinalPrice
+		units += sale.Quantity
+		r.sendTo(sale.BuyerID, Message{
+			Kind:          AuctionWon,
+			Message:       "You won units of the auction",
+			Amount:        sale.FinalPrice,
+			UserID:        sale.BuyerID,
+			Quantity:      sale.Quantity,
+			ClearingPrice: &result.ClearingPrice,
+		})
+	}
+
+	r.sendTo(result.Product.SellerID, Message{
+		Kind:          AuctionSold,
+		Message:       "Your units were sold",
+		Amount:        total,
+		UserID:        result.Product.SellerID,
+		Quantity:      units,
+		ClearingPrice: &result.ClearingPrice,
+	})
 }
 
 func (r *AuctionRoom) Run() {
-	slog.Info("Auction has started", "auctionId", r.Id)
+	slog.Info("Auction has started", "auctionId", r.Id, "auctionEnd", r.AuctionEnd)
+	r.deadline = time.NewTimer(time.Until(r.AuctionEnd))
 	defer func() {
-		close(r.Broadcast)
-		close(r.Register)
-		close(r.Unregister)
+		r.deadline.Stop()
+		r.cancel()
+		for _, w := range r.watchers {
+			close(w.updates)
+		}
 	}()
+
+	// Dutch auctions announce every drop of their asking price.
+	var priceDrop *time.Timer
+	var priceDrops <-chan time.Time
+	if at, ok := NextPriceDrop(r.Product, time.Now()); ok {
+		priceDrop = time.NewTimer(time.Until(at))
+		defer priceDrop.Stop()
+		priceDrops = priceDrop.C
+	}
+
 	for {
 		select {
 		case client := <-r.Register:
@@ -116,42 +1069,153 @@ func (r *AuctionRoom) Run() {
 			r.unregisterClient(client)
 		case message := <-r.Broadcast:
 			r.brodcastMessage(message)
-		case <-r.Context.Done():
-			slog.Info("Auction has ended", "auctionId", r.Id)
-			for _, client := range r.Clients {
-				client.Send <- Message{Kind: AuctionFinished, Message: "Auction has been finished"}
+		case w := <-r.watch:
+			r.watchers = append(r.watchers, w)
+		case now := <-priceDrops:
+			r.announcePrice(DutchPrice(r.Product, now))
+			if at, ok := NextPriceDrop(r.Product, now); ok {
+				priceDrop.Reset(time.Until(at))
+			} else {
+				priceDrops = nil
 			}
+		case <-r.deadline.C:
+			slog.Info("Auction has ended", "auctionId", r.Id)
+			r.finishAuction()
+			return
+		case <-r.Context.Done():
+			slog.Info("Auction has been closed early", "auctionId", r.Id)
+			r.finishAuction()
 			return
 		}
 	}
 }
 
-func NewAuctionRoom(ctx context.Context, id uuid.UUID, BidsService BidsService) *AuctionRoom {
+func NewAuctionRoom(product pgstore.Product, BidsService BidsService) *AuctionRoom {
+	ctx, cancel := context.WithCancel(context.Background())
 	return &AuctionRoom{
-		Id:          id,
+		Id:          product.ID,
+		Product:     product,
+		AuctionEnd:  product.AuctionEnd,
 		Broadcast:   make(chan Message),
 		Register:    make(chan *Client),
 		Unregister:  make(chan *Client),
-		Clients:     make(map[uuid.UUID]*Client),
+		Clients:     make(map[*Client]bool),
 		Context:     ctx,
 		BidsService: BidsService,
+		cancel:      cancel,
+		watch:       make(chan watcher),
+		// Sequence numbers start from the time the room opened so they keep
+		// growing when the room is restored after a restart.
+		seq: uint64(time.Now().UnixMicro()),
 	}
 }
 
+// Hub is a room clients exchange messages with: an auction or a sale event.
+type Hub interface {
+	channels() (broadcast chan<- Message, unregister chan<- *Client, done <-chan struct{})
+}
+
 type Client struct {
-	Room   *AuctionRoom
+	Room   Hub
 	Conn   *websocket.Conn
 	Send   chan Message
 	UserID uuid.UUID
+	// Since is the sequence number of the last event a reconnecting client
+	// received, the events after it are replayed when it joins.
+	Since uint64
+	// Policy decides what happens when the client falls behind.
+	Policy SlowClientPolicy
+	// Protocol is the subprotocol negotiated for the connection, see
+	// ProtocolV1 and ProtocolV2.
+	Protocol string
+
+	// lagging and dropped are only touched by the room event loop, see
+	// Client.push.
+	lagging bool
+	dropped bool
 }
 
-func NewClient(room *AuctionRoom, conn *websocket.Conn, userId uuid.UUID) *Client {
-	return &Client{
+func NewClient(room Hub, conn *websocket.Conn, userId uuid.UUID) *Client {
+	client := &Client{
 		Room:   room,
 		Conn:   conn,
 		Send:   make(chan Message, 512),
 		UserID: userId,
+		Policy: DisconnectSlowClients,
+		// Clients that ask for no subprotocol speak the original format.
+		Protocol: ProtocolV1,
+	}
+	if conn.Subprotocol() != "" {
+		client.Protocol = conn.Subprotocol()
 	}
+	return client
+}
+
+// SlowClientPolicy is how a room treats a client that reads its messages
+// slower than they come, once its Send buffer is full.
+type SlowClientPolicy string
+
+const (
+	// DisconnectSlowClients drops the message and the client with it.
+	DisconnectSlowClients SlowClientPolicy = "disconnect"
+	// CoalesceSlowClients skips messages until the client has drained half
+	// of its buffer, then sends it the latest state of the room instead.
+	CoalesceSlowClients SlowClientPolicy = "coalesce"
+)
+
+var (
+	// droppedMessages counts the messages rooms could not hand to a slow
+	// client, by policy of the client, or to a slow watcher.
+	droppedMessages = expvar.NewMap("room_dropped_messages")
+	// slowClientsDisconnected counts the clients dropped for falling behind.
+	slowClientsDisconnected = expvar.NewInt("room_slow_clients_disconnected")
+	// slowWatchersDropped counts the watchers, such as sale events, dropped
+	// for falling behind, see AuctionRoom.Watch.
+	slowWatchersDropped = expvar.NewInt("room_slow_watchers_dropped")
+)
+
+// push hands m to the Send buffer of c without ever blocking the room event
+// loop, so a stalled connection can not hold up everyone else. Once a
+// coalescing client has room again it receives what resync returns in place
+// of m. push reports false when the client was dropped, its Send channel is
+// closed then and the room must forget it.
+func (c *Client) push(m Message, resync func(c *Client, m Message) []Message) bool {
+	if c.dropped {
+		return false
+	}
+
+	m = m.forUser(c.UserID)
+	messages := []Message{m}
+	if c.lagging {
+		if len(c.Send) > cap(c.Send)/2 {
+			droppedMessages.Add(string(c.Policy), 1)
+			return true
+		}
+		c.lagging = false
+		messages = resync(c, m)
+	}
+
+	for _, m := range messages {
+		select {
+		case c.Send <- m:
+			continue
+		default:
+		}
+
+		droppedMessages.Add(string(c.Policy), 1)
+		if c.Policy == CoalesceSlowClients {
+			c.lagging = true
+			return true
+		}
+
+		slog.Info("Dropping slow client", "Client", c)
+		slowClientsDisconnected.Add(1)
+		c.dropped = true
+		close(c.Send)
+		return false
+	}
+
+	return true
 }
 
 const (
@@ -161,9 +1225,28 @@ const (
 	pingPeriod     = (readDeadline * 9) / 10 // 90% of readDeadline
 )
 
+// deliver hands m to the room event loop unless the auction is already over.
+func (c *Client) deliver(m Message) bool {
+	broadcast, _, done := c.Room.channels()
+	select {
+	case broadcast <- m:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+func (c *Client) unregister() {
+	_, unregister, done := c.Room.channels()
+	select {
+	case unregister <- c:
+	case <-done:
+	}
+}
+
 func (c *Client) ReadEventLoop() {
 	defer func() {
-		c.Room.Unregister <- c
+		c.unregister()
 		c.Conn.Close()
 	}()
 
@@ -175,23 +1258,22 @@ func (c *Client) ReadEventLoop() {
 	})
 
 	for {
-		var m Message
-		m.UserID = c.UserID
-		err := c.Conn.ReadJSON(&m)
+		_, reader, err := c.Conn.NextReader()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 				slog.Error("Unexpected close error", "error", err)
-				return
 			}
-			c.Room.Broadcast <- Message{
-				Kind:    InvalidJSON,
-				Message: "this message should be a valid JSON",
-				UserID:  m.UserID,
-			}
-			continue
+			return
 		}
 
-		c.Room.Broadcast <- m
+		m := decodeMessage(c.Protocol, reader)
+		// The sender is always the authenticated user of this connection.
+		m.UserID = c.UserID
+		m.from = c
+
+		if !c.deliver(m) {
+			return
+		}
 	}
 }
 
@@ -205,22 +1287,30 @@ func (c *Client) WriteEventLoop() {
 	for {
 		select {
 		case message, ok := <-c.Send:
+			// The room closes Send when it drops a client that fell behind,
+			// see Client.push.
 			if !ok {
-				c.Conn.WriteJSON(Message{
-					Kind:    websocket.CloseMessage,
-					Message: "Closing websocket conn",
-				})
-				return
-			}
-			if message.Kind == AuctionFinished {
-				close(c.Send)
+				c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "client too slow"))
 				return
 			}
 
 			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
-			err := c.Conn.WriteJSON(message)
+			err := c.Conn.WriteJSON(encodeMessage(c.Protocol, message))
 			if err != nil {
-				c.Room.Unregister <- c
+				c.unregister()
+				return
+			}
+
+			if message.Kind == TooManyConnections {
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, message.Message))
+				return
+			}
+
+			// A sale event relays the end of each of its lots, which carry
+			// their ProductID, and only closes once every lot is over.
+			if message.Kind.closesAuction() && message.ProductID == uuid.Nil {
+				c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, message.Message))
 				return
 			}
 		case <-ticker.C:
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"encoding/json"
+	"expvar"
+	"gobid/internal/store/pgstore"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newTestClient(policy SlowClientPolicy, buffer int) *Client {
+	return &Client{
+		Send:   make(chan Message, buffer),
+		UserID: uuid.New(),
+		Policy: policy,
+	}
+}
+
+func fill(c *Client) {
+	for len(c.Send) < cap(c.Send) {
+		c.Send <- Message{Kind: NewBidPlaced}
+	}
+}
+
+func droppedCount(policy SlowClientPolicy) int64 {
+	if v, ok := droppedMessages.Get(string(policy)).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func noResync(t *testing.T) func(*Client, Message) []Message {
+	return func(*Client, Message) []Message {
+		t.Fatal("resync called")
+		return nil
+	}
+}
+
+func TestClientPushDisconnectsSlowClient(t *testing.T) {
+	c := newTestClient(DisconnectSlowClients, 4)
+	fill(c)
+
+	before := droppedCount(DisconnectSlowClients)
+	disconnected := slowClientsDisconnected.Value()
+
+	if c.push(Message{Kind: NewBidPlaced}, noResync(t)) {
+		t.Fatal("push into a full buffer kept the client")
+	}
+	if c.push(Message{Kind: NewBidPlaced}, noResync(t)) {
+		t.Fatal("push to a dropped client kept it")
+	}
+
+	for range cap(c.Send) {
+		<-c.Send
+	}
+	if _, ok := <-c.Send; ok {
+		t.Fatal("Send is still open")
+	}
+
+	if after := droppedCount(DisconnectSlowClients); after != before+1 {
+		t.Errorf("dropped messages went from %d to %d, want one more", before, after)
+	}
+	if got := slowClientsDisconnected.Value(); got != disconnected+1 {
+		t.Errorf("disconnected clients went from %d to %d, want one more", disconnected, got)
+	}
+}
+
+func TestClientPushCoalescesSlowClient(t *testing.T) {
+	c := newTestClient(CoalesceSlowClients, 4)
+	fill(c)
+
+	if !c.push(Message{Kind: NewBidPlaced, Seq: 1}, noResync(t)) {
+		t.Fatal("push dropped a coalescing client")
+	}
+	if !c.lagging {
+		t.Fatal("client is not marked as lagging")
+	}
+
+	// Nothing is delivered until the client has drained half its buffer.
+	<-c.Send
+	if !c.push(Message{Kind: NewBidPlaced, Seq: 2}, noResync(t)) {
+		t.Fatal("push dropped a coalescing client")
+	}
+	if len(c.Send) != 3 {
+		t.Fatalf("%d messages waiting, want 3", len(c.Send))
+	}
+
+	<-c.Send
+	var resynced Message
+	state := Message{Kind: AuctionSnapshot, Seq: 3}
+	c.push(Message{Kind: NewBidPlaced, Seq: 3}, func(got *Client, m Message) []Message {
+		if got != c {
+			t.Error("resync called for another client")
+		}
+		resynced = m
+		return []Message{state}
+	})
+
+	if resynced.Seq != 3 {
+		t.Errorf("resync got seq %d, want 3", resynced.Seq)
+	}
+	if c.lagging {
+		t.Error("client still lagging after resync")
+	}
+	for len(c.Send) > 1 {
+		<-c.Send
+	}
+	if got := <-c.Send; got.Kind != AuctionSnapshot || got.Seq != 3 {
+		t.Errorf("client got %v, want the resync state", got)
+	}
+}
+
+// TestFrozenClientDoesNotDelayOthers announces far more messages than a
+// client buffer holds while one client, and one watcher, never read theirs.
+// Every other bidder has to get every message right away.
+func TestFrozenClientDoesNotDelayOthers(t *testing.T) {
+	for _, policy := range []SlowClientPolicy{DisconnectSlowClients, CoalesceSlowClients} {
+		t.Run(string(policy), func(t *testing.T) {
+			room := NewAuctionRoom(pgstore.Product{ID: uuid.New(), AuctionEnd: time.Now().Add(time.Hour)}, BidsService{})
+			defer room.cancel()
+
+			frozen := newTestClient(policy, 512)
+			room.Clients[frozen] = true
+
+			frozenWatcher := make(chan Message, 8)
+			room.watchers = append(room.watchers, watcher{updates: frozenWatcher, done: make(chan struct{})})
+
+			const messages = 2000
+			const maxLatency = 100 * time.Millisecond
+
+			// Bidders that do read get a buffer large enough for every
+			// message, only the frozen client can fall behind.
+			const bidders = 8
+			readers := make([]*Client, bidders)
+			for i := range readers {
+				readers[i] = newTestClient(DisconnectSlowClients, messages)
+				room.Clients[readers[i]] = true
+			}
+
+			// sent[i] is when the message with sequence number first+i was
+			// announced.
+			first := room.seq + 1
+			sent := make([]time.Time, messages)
+
+			latencies := make(chan time.Duration, bidders)
+			for _, reader := range readers {
+				go func(c *Client) {
+					var worst time.Duration
+					for range messages {
+						m := <-c.Send
+						worst = max(worst, time.Since(sent[m.Seq-first]))
+					}
+					latencies <- worst
+				}(reader)
+			}
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for i := range messages {
+					sent[i] = time.Now()
+					room.announce(Message{Kind: NewBidPlaced}, uuid.Nil)
+				}
+			}()
+
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("the room is blocked on the frozen client")
+			}
+
+			for range bidders {
+				select {
+				case worst := <-latencies:
+					if worst > maxLatency {
+						t.Errorf("a bidder waited %s for a message, want at most %s", worst, maxLatency)
+					}
+				case <-time.After(10 * time.Second):
+					t.Fatal("a bidder did not get every message")
+				}
+			}
+
+			if policy == DisconnectSlowClients && room.Clients[frozen] {
+				t.Error("frozen client was not dropped")
+			}
+			if policy == CoalesceSlowClients && (!room.Clients[frozen] || !frozen.lagging) {
+				t.Error("frozen client was not kept as lagging")
+			}
+			if len(room.watchers) != 0 {
+				t.Error("frozen watcher was not dropped")
+			}
+		})
+	}
+}
+
+func TestUnsettledAuctionIsSettledLater(t *testing.T) {
+	bids := NewBidsService(unreachablePool(t), DefaultAuctionConfig())
+
+	room := NewAuctionRoom(pgstore.Product{ID: uuid.New(), AuctionEnd: time.Now()}, bids)
+	defer room.cancel()
+	room.finishAuction()
+	if !room.unsettled {
+		t.Fatal("room is not marked as unsettled")
+	}
+
+	lobby := &AuctionLobby{Rooms: make(map[uuid.UUID]*AuctionRoom)}
+	lobby.settle(room.Id, bids, settleRetryDelay)
+
+	lobby.Lock()
+	defer lobby.Unlock()
+	timer, ok := lobby.settling[room.Id]
+	if !ok {
+		t.Fatal("settlement was not tried again")
+	}
+	timer.Stop()
+}
+
+// TestBroadcastsHideBidders outbids a bidder with a proxy bid of another
+// one. Every client has to be told who leads by pseudonym, and only the
+// leader that it is them.
+func TestBroadcastsHideBidders(t *testing.T) {
+	bids := NewBidsService(unreachablePool(t), DefaultAuctionConfig())
+	room := NewAuctionRoom(pgstore.Product{ID: uuid.New(), AuctionEnd: time.Now().Add(time.Hour)}, bids)
+	defer room.cancel()
+
+	leader := newTestClient(DisconnectSlowClients, 8)
+	outbid := newTestClient(DisconnectSlowClients, 8)
+	watcher := newTestClient(DisconnectSlowClients, 8)
+	for _, c := range []*Client{leader, outbid, watcher} {
+		room.Clients[c] = true
+	}
+
+	bid := pgstore.Bid{BidderID: leader.UserID, BidAmount: 12_00}
+	room.announceBid(Message{Kind: PlaceBid, UserID: outbid.UserID, Amount: 11_00}, BidOutcome{Leading: bid, Changed: true}, nil, SuccessfullyPlacedBid, "placed")
+
+	// The auction is then closed as a sealed one would be.
+	room.result = &AuctionResult{Bids: []pgstore.Bid{bid}}
+	room.finishAuction()
+
+	handle := bids.BidderHandle(room.Id, leader.UserID)
+	for _, c := range []*Client{leader, outbid, watcher} {
+		isYou := c == leader
+		for len(c.Send) > 0 {
+			m := <-c.Send
+
+			raw, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(raw), leader.UserID.String()) {
+				t.Errorf("%s reveals the bidder: %s", messageTypes[m.Kind], raw)
+			}
+
+			switch m.Kind {
+			case NewBidPlaced:
+				if m.Bidder != handle || m.IsYou != isYou {
+					t.Errorf("new bid by %q, is you %v, want %q and %v", m.Bidder, m.IsYou, handle, isYou)
+				}
+			case BidsRevealed:
+				if len(m.Bids) != 1 || m.Bids[0].Bidder != handle || m.Bids[0].IsYou != isYou {
+					t.Errorf("revealed %+v, want one bid by %q with is you %v", m.Bids, handle, isYou)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"gobid/internal/money"
+	"gobid/internal/store/pgstore"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuctionStatus string
+
+const (
+	AuctionStatusScheduled AuctionStatus = "scheduled"
+	AuctionStatusOpen      AuctionStatus = "open"
+	// AuctionStatusClosing is an auction past its deadline that has not been
+	// settled yet.
+	AuctionStatusClosing   AuctionStatus = "closing"
+	AuctionStatusSold      AuctionStatus = "sold"
+	AuctionStatusUnsold    AuctionStatus = "unsold"
+	AuctionStatusCancelled AuctionStatus = "cancelled"
+)
+
+// AuctionState is what bidders may know about an auction at a given time.
+// It never discloses the reserve price, only whether it is met.
+type AuctionState struct {
+	Product pgstore.Product
+	Status  AuctionStatus
+	// CurrentPrice is the price a bidder has to beat, or the asking price of
+	// a dutch auction. It is nil while the bids of a sealed-bid auction are
+	// hidden.
+	CurrentPrice *money.Amount
+	LeadingBid   pgstore.Bid
+	HasLeading   bool
+	BidCount     int64
+	// NextBid is the lowest acceptable amount of the next bid, the highest
+	// one in reverse auctions, while the auction is open.
+	NextBid    *money.Amount
+	ReserveMet *bool
+}
+
+func auctionStatus(product pgstore.Product, at time.Time) AuctionStatus {
+	switch {
+	case product.CancelledAt.Valid:
+		return AuctionStatusCancelled
+	case product.ClosedAt.Valid && product.IsSold:
+		return AuctionStatusSold
+	case product.ClosedAt.Valid:
+		return AuctionStatusUnsold
+	case at.Before(product.AuctionStart):
+		return AuctionStatusScheduled
+	case !at.Before(product.AuctionEnd):
+		return AuctionStatusClosing
+	}
+	return AuctionStatusOpen
+}
+
+// AuctionState reads the current state of the auction of product.
+func (bs *BidsService) AuctionState(ctx context.Context, product pgstore.Product) (AuctionState, error) {
+	now := time.Now()
+	state := AuctionState{
+		Product: product,
+		Status:  auctionStatus(product, now),
+	}
+
+	var err error
+	state.BidCount, err = bs.queries.CountBidsByProductId(ctx, product.ID)
+	if err != nil {
+		return AuctionState{}, err
+	}
+
+	// Sealed bids stay secret until the auction is settled.
+	sealed := IsSealed(product.AuctionType) && !product.ClosedAt.Valid
+	if !sealed {
+		state.LeadingBid, state.HasLeading, err = getLeadingBid(ctx, bs.queries, product)
+		if err != nil {
+			return AuctionState{}, err
+		}
+	}
+
+	price := product.Baseprice
+	switch {
+	case sealed:
+	case IsMultiUnit(product.AuctionType):
+		bids, err := bs.queries.GetBidsByProductId(ctx, product.ID)
+		if err != nil {
+			return AuctionState{}, err
+		}
+		if _, clearingPrice, soldOut := allocate(product, bids); soldOut {
+			price = clearingPrice
+		}
+		state.CurrentPrice = &price
+	case product.AuctionType == pgstore.AuctionTypeDutch && !state.HasLeading:
+		price = DutchPrice(product, now)
+		state.CurrentPrice = &price
+	default:
+		if state.HasLeading {
+			price = state.LeadingBid.BidAmount
+		}
+		state.CurrentPrice = &price
+		state.ReserveMet = reserveMet(product, price)
+	}
+
+	if state.Status != AuctionStatusOpen {
+		return state, nil
+	}
+
+	var next money.Amount
+	switch {
+	case product.AuctionType == pgstore.AuctionTypeEnglish:
+		next = bs.minimumNextBid(product, state.LeadingBid, state.HasLeading)
+	case product.AuctionType == pgstore.AuctionTypeReverse:
+		next = bs.maximumNextBid(product, state.LeadingBid, state.HasLeading)
+	case IsMultiUnit(product.AuctionType):
+		next = price + bs.incrementsFor(product).IncrementFor(price)
+	case IsSealed(product.AuctionType):
+		next = product.Baseprice
+	default:
+		return state, nil
+	}
+	state.NextBid = &next
+
+	return state, nil
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// BidsPage is a page of bids, newest first. NextCursor is empty on the last
+// page.
+type BidsPage struct {
+	Bids       []pgstore.Bid
+	NextCursor string
+}
+
+// ListBids returns up to limit bids of product placed before cursor, an
+// empty cursor starting from the latest bid.
+func (bs *BidsService) ListBids(ctx context.Context, product_id uuid.UUID, cursor string, limit int32) (BidsPage, error) {
+	before := time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+	beforeID := uuid.Max
+	if cursor != "" {
+		var err error
+		if before, beforeID, err = decodeBidCursor(cursor); err != nil {
+			return BidsPage{}, err
+		}
+	}
+
+	bids, err := bs.queries.ListBidsByProductIdBefore(ctx, pgstore.ListBidsByProductIdBeforeParams{
+		ProductID:       product_id,
+		BeforeCreatedAt: before,
+		BeforeID:        beforeID,
+		PageSize:        limit + 1,
+	})
+	if err != nil {
+		return BidsPage{}, err
+	}
+
+	page := BidsPage{Bids: bids}
+	if len(bids) > int(limit) {
+		page.Bids = bids[:limit]
+		last := page.Bids[limit-1]
+		page.NextCursor = encodeBidCursor(last.CreatedAt, last.ID)
+	}
+
+	return page, nil
+}
+
+func encodeBidCursor(createdAt time.Time, id uuid.UUID) string {
+	raw := binary.BigEndian.AppendUint64(nil, uint64(createdAt.UnixMicro()))
+	raw = append(raw, id[:]...)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeBidCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) != 8+len(uuid.UUID{}) {
+		return time.Time{}, uuid.UUID{}, ErrInvalidCursor
+	}
+
+	id, err := uuid.FromBytes(raw[8:])
+	if err != nil {
+		return time.Time{}, uuid.UUID{}, ErrInvalidCursor
+	}
+
+	return time.UnixMicro(int64(binary.BigEndian.Uint64(raw[:8]))), id, nil
+}
+
+// BidderHandle is the pseudonym of bidder_id in the auction of product_id.
+// It is the same for every bid of the bidder in that auction but can not be
+// linked to their account or to their bids in other auctions.
+func (bs *BidsService) BidderHandle(product_id, bidder_id uuid.UUID) string {
+	mac := hmac.New(sha256.New, bs.config.BidderHandleKey)
+	mac.Write(product_id[:])
+	mac.Write(bidder_id[:])
+	return "bidder-" + hex.EncodeToString(mac.Sum(nil)[:4])
+}
+
+// PublicBid is a bid as any bidder may see it, its bidder hidden behind
+// their BidderHandle.
+type PublicBid struct {
+	Bidder    string       `json:"bidder"`
+	IsYou     bool         `json:"is_you"`
+	Amount    money.Amount `json:"amount"`
+	Quantity  int32        `json:"quantity"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// PublicBids hides the bidders of bids placed on product_id from viewer,
+// who only recognizes their own.
+func (bs *BidsService) PublicBids(product_id uuid.UUID, bids []pgstore.Bid, viewer uuid.UUID) []PublicBid {
+	public := make([]PublicBid, 0, len(bids))
+	for _, bid := range bids {
+		public = append(public, PublicBid{
+			Bidder:    bs.BidderHandle(product_id, bid.BidderID),
+			IsYou:     bid.BidderID == viewer,
+			Amount:    bid.BidAmount,
+			Quantity:  bid.Quantity,
+			CreatedAt: bid.CreatedAt,
+		})
+	}
+	return public
+}
@@ -2,58 +2,1317 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"gobid/internal/money"
 	"gobid/internal/store/pgstore"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type BidsService struct {
 	pool    *pgxpool.Pool
 	queries *pgstore.Queries
+	config  AuctionConfig
 }
 
-func NewBidsService(pool *pgxpool.Pool) BidsService {
+func NewBidsService(pool *pgxpool.Pool, config AuctionConfig) BidsService {
 	return BidsService{
 		pool:    pool,
 		queries: pgstore.New(pool),
+		config:  config,
 	}
 }
 
-var ErrBidIsTooLow = errors.New("the bid value is too low")
+var (
+	ErrBidIsTooLow      = errors.New("the bid value is too low")
+	ErrBidIsTooHigh     = errors.New("the bid value is too high")
+	ErrAuctionClosed    = errors.New("the auction is closed")
+	ErrWrongAuctionType = errors.New("this action is not available for this auction type")
+	ErrAuctionNotOpen   = errors.New("the auction has not started yet")
+	ErrSelfBid          = errors.New("sellers can not bid on their own products")
+)
+
+// BidTooLowError is returned when a bid is below the minimum acceptable
+// amount, which it carries so bidders know what to offer next.
+type BidTooLowError struct {
+	Minimum money.Amount
+}
+
+func (e *BidTooLowError) Error() string {
+	return fmt.Sprintf("%s, the minimum acceptable bid is %s", ErrBidIsTooLow, e.Minimum)
+}
+
+func (e *BidTooLowError) Is(target error) bool {
+	return target == ErrBidIsTooLow
+}
+
+// BidTooHighError is the reverse auction counterpart of BidTooLowError, it
+// carries the maximum acceptable amount.
+type BidTooHighError struct {
+	Maximum money.Amount
+}
+
+func (e *BidTooHighError) Error() string {
+	return fmt.Sprintf("%s, the maximum acceptable bid is %s", ErrBidIsTooHigh, e.Maximum)
+}
+
+func (e *BidTooHighError) Is(target error) bool {
+	return target == ErrBidIsTooHigh
+}
+
+// BidOutcome describes an auction right after a bid was processed.
+type BidOutcome struct {
+	// Leading is the bid leading the auction, the lowest one in reverse
+	// auctions. It may be a proxy bid placed on behalf of another bidder.
+	Leading pgstore.Bid
+	// Changed reports whether the visible price moved.
+	Changed bool
+	// ReserveMet is nil when the auction has no reserve price.
+	ReserveMet *bool
+	// AuctionEnd is the deadline of the auction, pushed out by Extended
+	// when the bid landed inside the soft-close window.
+	AuctionEnd time.Time
+	Extended   bool
+	// ClearingPrice is the lowest winning price per unit of a multi-unit
+	// auction.
+	ClearingPrice money.Amount
+}
+
+// newOutcome builds the outcome of a bid and applies the soft-close rule: a
+// price change in the final SoftCloseWindow pushes the deadline out to
+// SoftCloseExtension after it.
+func (bs *BidsService) newOutcome(ctx context.Context, queries *pgstore.Queries, product pgstore.Product, leadingBid pgstore.Bid, changed bool) (BidOutcome, error) {
+	outcome := BidOutcome{
+		Leading:    leadingBid,
+		Changed:    changed,
+		ReserveMet: reserveMet(product, leadingBid.BidAmount),
+		AuctionEnd: product.AuctionEnd,
+	}
+
+	if !changed || bs.config.SoftCloseWindow <= 0 || time.Until(product.AuctionEnd) > bs.config.SoftCloseWindow {
+		return outcome, nil
+	}
+
+	// The deadline moves to SoftCloseExtension after the bid, never by it,
+	// so a burst of late bids can not push it out indefinitely.
+	end := time.Now().Add(bs.config.SoftCloseExtension)
+	if !end.After(product.AuctionEnd) {
+		return outcome, nil
+	}
+
+	outcome.AuctionEnd = end
+	outcome.Extended = true
+
+	if err := queries.UpdateProductAuctionEnd(ctx, pgstore.UpdateProductAuctionEndParams{
+		ID:         product.ID,
+		AuctionEnd: outcome.AuctionEnd,
+	}); err != nil {
+		return BidOutcome{}, err
+	}
 
-func (bs *BidsService) PlaceBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount float64) (pgstore.Bid, error) {
-	product, err := bs.queries.GetProductById(ctx, product_id)
+	return outcome, nil
+}
+
+// reserveMet reports whether amount reaches the hidden reserve price of the
+// product, or nil when the product has none.
+func reserveMet(product pgstore.Product, amount money.Amount) *bool {
+	if product.ReservePrice == nil {
+		return nil
+	}
+	met := amount >= *product.ReservePrice
+	return &met
+}
 
+// lockOpenProduct loads the product and holds a row lock on it until the
+// transaction ends, so every bid on the same product is handled one at a time.
+func lockOpenProduct(ctx context.Context, queries *pgstore.Queries, product_id uuid.UUID) (pgstore.Product, error) {
+	product, err := queries.GetProductByIdForUpdate(ctx, product_id)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return pgstore.Bid{}, err
+			return pgstore.Product{}, ErrProductNotFound
 		}
+		return pgstore.Product{}, err
 	}
 
-	highestBid, err := bs.queries.GetHighestBidByProductId(ctx, product_id)
+	if product.ClosedAt.Valid || !time.Now().Before(product.AuctionEnd) {
+		return pgstore.Product{}, ErrAuctionClosed
+	}
+
+	if time.Now().Before(product.AuctionStart) {
+		return pgstore.Product{}, ErrAuctionNotOpen
+	}
 
+	return product, nil
+}
+
+// lockBiddableProduct is lockOpenProduct for bids, which the seller of the
+// product may never place.
+func lockBiddableProduct(ctx context.Context, queries *pgstore.Queries, product_id, bidder_id uuid.UUID) (pgstore.Product, error) {
+	product, err := lockOpenProduct(ctx, queries, product_id)
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	if product.SellerID == bidder_id {
+		return pgstore.Product{}, ErrSelfBid
+	}
+
+	return product, nil
+}
+
+// getLeadingBid returns the bid leading the auction: the highest one, or the
+// lowest one in reverse auctions.
+func getLeadingBid(ctx context.Context, queries *pgstore.Queries, product pgstore.Product) (pgstore.Bid, bool, error) {
+	var leadingBid pgstore.Bid
+	var err error
+	if product.AuctionType == pgstore.AuctionTypeReverse {
+		leadingBid, err = queries.GetLowestBidByProductId(ctx, product.ID)
+	} else {
+		leadingBid, err = queries.GetHighestBidByProductId(ctx, product.ID)
+	}
 	if err != nil {
-		if !errors.Is(err, pgx.ErrNoRows) {
-			return pgstore.Bid{}, err
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.Bid{}, false, nil
 		}
+		return pgstore.Bid{}, false, err
+	}
+
+	return leadingBid, true, nil
+}
+
+func createBid(ctx context.Context, queries *pgstore.Queries, arg pgstore.CreateBidParams) (pgstore.Bid, error) {
+	bid, err := queries.CreateBid(ctx, arg)
+	if err != nil {
+		return pgstore.Bid{}, bidError(err)
+	}
+
+	return bid, nil
+}
+
+// bidError translates the errors raised by the bids table trigger.
+func bidError(err error) error {
+	var pgErr *pgconn.PgError
+
+	if errors.As(err, &pgErr) && pgErr.Code == "23514" { //postgres code for check_violation
+		return ErrBidIsTooLow
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" { //postgres code for unique_violation
+		return ErrAuctionClosed
+	}
+	if errors.As(err, &pgErr) && pgErr.Code == "42501" { //postgres code for insufficient_privilege
+		return ErrSelfBid
+	}
+	return err
+}
+
+// PlaceBid runs inside a transaction that holds a row lock on the product, so
+// concurrent bids on the same product are validated and inserted one at a
+// time. The bids table enforces the same rule through a trigger. Reverse
+// auctions take bids below the lowest one instead.
+func (bs *BidsService) PlaceBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Amount) (BidOutcome, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, bidder_id)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	isReverse := product.AuctionType == pgstore.AuctionTypeReverse
+	if product.AuctionType != pgstore.AuctionTypeEnglish && !isReverse {
+		return BidOutcome{}, ErrWrongAuctionType
+	}
+
+	leadingBid, hasLeadingBid, err := getLeadingBid(ctx, queries, product)
+	if err != nil {
+		return BidOutcome{}, err
 	}
 
-	if product.Baseprice >= amount || highestBid.BidAmount >= amount {
-		return pgstore.Bid{}, ErrBidIsTooLow
+	if isReverse {
+		if amount <= 0 {
+			return BidOutcome{}, ErrBidIsTooLow
+		}
+		if maximum := bs.maximumNextBid(product, leadingBid, hasLeadingBid); amount > maximum {
+			return BidOutcome{}, &BidTooHighError{Maximum: maximum}
+		}
+	} else if minimum := bs.minimumNextBid(product, leadingBid, hasLeadingBid); amount < minimum {
+		return BidOutcome{}, &BidTooLowError{Minimum: minimum}
 	}
 
-	highestBid, err = bs.queries.CreateBid(ctx, pgstore.CreateBidParams{
+	bid, err := createBid(ctx, queries, pgstore.CreateBidParams{
 		ProductID: product_id,
 		BidderID:  bidder_id,
 		BidAmount: amount,
 	})
+	if err != nil {
+		if isReverse && errors.Is(err, ErrBidIsTooLow) {
+			return BidOutcome{}, ErrBidIsTooHigh
+		}
+		return BidOutcome{}, err
+	}
+
+	leadingBid = bid
+	if !isReverse {
+		leadingBid, err = bs.resolveProxyBids(ctx, queries, product, bid, true)
+		if err != nil {
+			return BidOutcome{}, err
+		}
+	}
+
+	outcome, err := bs.newOutcome(ctx, queries, product, leadingBid, true)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return BidOutcome{}, err
+	}
+
+	return outcome, nil
+}
+
+// PlaceMaxBid stores a secret ceiling for bidder_id. The bidder is then kept
+// in the lead automatically, one increment at a time, until someone bids
+// above that ceiling.
+func (bs *BidsService) PlaceMaxBid(ctx context.Context, product_id, bidder_id uuid.UUID, maxAmount money.Amount) (BidOutcome, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, bidder_id)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeEnglish {
+		return BidOutcome{}, ErrWrongAuctionType
+	}
 
+	highestBid, hasHighestBid, err := getLeadingBid(ctx, queries, product)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	current, err := queries.GetMaxBidByBidder(ctx, pgstore.GetMaxBidByBidderParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+	})
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return BidOutcome{}, err
+	}
+
+	// A leading bidder only has to stay above their own bid, anybody else has
+	// to be able to outbid the current price. Ceilings can never be lowered.
+	minimum := bs.minimumNextBid(product, highestBid, hasHighestBid)
+	if hasHighestBid && highestBid.BidderID == bidder_id {
+		minimum = highestBid.BidAmount + 1
+	}
+	minimum = max(minimum, current.MaxAmount)
+
+	if maxAmount < minimum {
+		return BidOutcome{}, &BidTooLowError{Minimum: minimum}
+	}
+
+	if _, err := queries.UpsertMaxBid(ctx, pgstore.UpsertMaxBidParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+		MaxAmount: maxAmount,
+	}); err != nil {
+		return BidOutcome{}, err
+	}
+
+	resolved, err := bs.resolveProxyBids(ctx, queries, product, highestBid, hasHighestBid)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	outcome, err := bs.newOutcome(ctx, queries, product, resolved, resolved.ID != highestBid.ID)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return BidOutcome{}, err
+	}
+
+	return outcome, nil
+}
+
+// incrementsFor returns the increment table of the product, falling back to
+// the global default when the seller did not set one.
+func (bs *BidsService) incrementsFor(product pgstore.Product) IncrementTable {
+	if len(product.BidIncrements) > 0 {
+		var table IncrementTable
+		if err := json.Unmarshal(product.BidIncrements, &table); err == nil && table.Validate() == nil {
+			return table
+		}
+	}
+	return bs.config.BidIncrements
+}
+
+// minimumNextBid is the lowest amount that takes the lead: one increment
+// above the highest bid, or above the base price while there are no bids.
+func (bs *BidsService) minimumNextBid(product pgstore.Product, highestBid pgstore.Bid, hasHighestBid bool) money.Amount {
+	price := product.Baseprice
+	if hasHighestBid {
+		price = highestBid.BidAmount
+	}
+	return price + bs.incrementsFor(product).IncrementFor(price)
+}
+
+// maximumNextBid is the highest amount that takes the lead of a reverse
+// auction: one increment below the lowest bid, or below the ceiling price
+// while there are no bids.
+func (bs *BidsService) maximumNextBid(product pgstore.Product, lowestBid pgstore.Bid, hasLowestBid bool) money.Amount {
+	price := product.Baseprice
+	if hasLowestBid {
+		price = lowestBid.BidAmount
+	}
+	return price - bs.incrementsFor(product).IncrementFor(price)
+}
+
+// resolveProxyBids lets the strongest max bid answer the current highest bid.
+// The strongest ceiling wins (ties go to whoever set it first) and only pays
+// one increment above the best competing ceiling, so a single bid is placed
+// and only the visible price is ever revealed.
+func (bs *BidsService) resolveProxyBids(ctx context.Context, queries *pgstore.Queries, product pgstore.Product, highestBid pgstore.Bid, hasHighestBid bool) (pgstore.Bid, error) {
+	maxBids, err := queries.GetTopMaxBidsByProductId(ctx, product.ID)
 	if err != nil {
 		return pgstore.Bid{}, err
 	}
 
-	return highestBid, nil
+	if len(maxBids) == 0 {
+		return highestBid, nil
+	}
+
+	leader := maxBids[0]
+	isLeading := hasHighestBid && highestBid.BidderID == leader.BidderID
+
+	// A plain bid at or above every ceiling keeps the lead.
+	if hasHighestBid && !isLeading && highestBid.BidAmount >= leader.MaxAmount {
+		return highestBid, nil
+	}
+
+	var challenger money.Amount
+	if hasHighestBid && !isLeading {
+		challenger = highestBid.BidAmount
+	}
+	for _, maxBid := range maxBids[1:] {
+		if maxBid.BidderID != leader.BidderID && maxBid.MaxAmount > challenger {
+			challenger = maxBid.MaxAmount
+		}
+	}
+
+	increments := bs.incrementsFor(product)
+
+	var price money.Amount
+	if isLeading {
+		price = highestBid.BidAmount
+		if challenger > highestBid.BidAmount {
+			price = min(challenger+increments.IncrementFor(challenger), leader.MaxAmount)
+		}
+	} else {
+		price = max(challenger+increments.IncrementFor(challenger), bs.minimumNextBid(product, highestBid, hasHighestBid))
+		price = min(price, leader.MaxAmount)
+	}
+
+	// A ceiling that covers the reserve bids straight up to it.
+	if reserve := product.ReservePrice; reserve != nil && leader.MaxAmount >= *reserve && price < *reserve {
+		price = *reserve
+	}
+
+	if price <= highestBid.BidAmount || price <= product.Baseprice {
+		return highestBid, nil
+	}
+
+	return createBid(ctx, queries, pgstore.CreateBidParams{
+		ProductID: product.ID,
+		BidderID:  leader.BidderID,
+		BidAmount: price,
+	})
+}
+
+var ErrAuctionAlreadySettled = errors.New("the auction has already been settled")
+
+type AuctionResult struct {
+	Product pgstore.Product
+	// LeadingBid is the bid leading the auction when it closed, the lowest
+	// one in reverse auctions.
+	LeadingBid pgstore.Bid
+	Sale       pgstore.Sale
+	Sold       bool
+	// Bids holds every bid of a sealed-bid auction, highest first, so they
+	// can be revealed once it is over.
+	Bids []pgstore.Bid
+	// ReserveNotMet is set when bidding ended below the reserve price.
+	ReserveNotMet bool
+	// BoughtNow is set when a bidder ended the auction at its buy-now price.
+	BoughtNow bool
+	// Cancelled is set when the seller called the auction off.
+	Cancelled bool
+	// Sales holds one sale per winning bid of a multi-unit auction, all of
+	// them sold at ClearingPrice per unit under uniform pricing.
+	Sales         []pgstore.Sale
+	ClearingPrice money.Amount
+}
+
+// SettleAuction closes the auction for product_id in a single transaction:
+// the highest bid wins, a sale is recorded with the final price and the
+// product is flagged as sold. Auctions without bids, or whose highest bid is
+// below the reserve price, are closed unsold. Sealed-bid auctions pay their
+// own first or second price, see sealedPrice, and reverse auctions are won by
+// the lowest bid.
+func (bs *BidsService) SettleAuction(ctx context.Context, product_id uuid.UUID) (AuctionResult, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := queries.GetProductByIdForUpdate(ctx, product_id)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if product.ClosedAt.Valid {
+		return AuctionResult{}, ErrAuctionAlreadySettled
+	}
+
+	result, err := bs.settle(ctx, queries, product)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return result, nil
+}
+
+// settle picks the winner of product and closes it, see SettleAuction.
+func (bs *BidsService) settle(ctx context.Context, queries *pgstore.Queries, product pgstore.Product) (AuctionResult, error) {
+	if IsMultiUnit(product.AuctionType) {
+		return bs.settleUnits(ctx, queries, product)
+	}
+
+	var err error
+	result := AuctionResult{Product: product}
+
+	var leadingBid pgstore.Bid
+	var hasLeadingBid bool
+	if IsSealed(product.AuctionType) {
+		result.Bids, err = queries.GetBidsByProductId(ctx, product.ID)
+		if err != nil {
+			return AuctionResult{}, err
+		}
+		if len(result.Bids) > 0 {
+			leadingBid, hasLeadingBid = result.Bids[0], true
+		}
+	} else {
+		leadingBid, hasLeadingBid, err = getLeadingBid(ctx, queries, product)
+		if err != nil {
+			return AuctionResult{}, err
+		}
+	}
+	result.LeadingBid = leadingBid
+
+	// In a reverse auction the product owner is the one buying, from the
+	// supplier behind the lowest bid.
+	buyerID, sellerID := leadingBid.BidderID, product.SellerID
+	if product.AuctionType == pgstore.AuctionTypeReverse {
+		buyerID, sellerID = product.SellerID, leadingBid.BidderID
+	}
+
+	if met := reserveMet(product, leadingBid.BidAmount); hasLeadingBid && met != nil && !*met {
+		result.ReserveNotMet = true
+	} else if hasLeadingBid {
+		result.Sale, err = queries.CreateSale(ctx, pgstore.CreateSaleParams{
+			ProductID:  product.ID,
+			BidID:      leadingBid.ID,
+			BuyerID:    buyerID,
+			SellerID:   sellerID,
+			FinalPrice: sealedPrice(product, result.Bids, leadingBid.BidAmount),
+		})
+		if err != nil {
+			return AuctionResult{}, err
+		}
+		result.Sold = true
+	}
+
+	if err := queries.CloseProduct(ctx, pgstore.CloseProductParams{ID: product.ID, IsSold: result.Sold}); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return result, nil
+}
+
+var ErrBuyNowUnavailable = errors.New("buy it now is not available for this auction")
+
+// BuyNow sells the product to buyer_id at its buy-now price and closes the
+// auction. It is only available while the highest bid is below the
+// configured share of the buy-now price.
+func (bs *BidsService) BuyNow(ctx context.Context, product_id, buyer_id uuid.UUID) (AuctionResult, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, buyer_id)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeEnglish {
+		return AuctionResult{}, ErrWrongAuctionType
+	}
+
+	if product.BuyNowPrice == nil {
+		return AuctionResult{}, ErrBuyNowUnavailable
+	}
+
+	highestBid, _, err := getLeadingBid(ctx, queries, product)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	threshold := *product.BuyNowPrice * money.Amount(bs.config.BuyNowThresholdPercent) / 100
+	if highestBid.BidAmount >= threshold {
+		return AuctionResult{}, ErrBuyNowUnavailable
+	}
+
+	bid, err := createBid(ctx, queries, pgstore.CreateBidParams{
+		ProductID: product_id,
+		BidderID:  buyer_id,
+		BidAmount: *product.BuyNowPrice,
+	})
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	sale, err := queries.CreateSale(ctx, pgstore.CreateSaleParams{
+		ProductID:  product.ID,
+		BidID:      bid.ID,
+		BuyerID:    buyer_id,
+		SellerID:   product.SellerID,
+		FinalPrice: bid.BidAmount,
+	})
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := queries.CloseProduct(ctx, pgstore.CloseProductParams{ID: product.ID, IsSold: true}); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return AuctionResult{
+		Product:    product,
+		LeadingBid: bid,
+		Sale:       sale,
+		Sold:       true,
+		BoughtNow:  true,
+	}, nil
+}
+
+// decrementInterval is how often the price of a dutch auction drops, or zero
+// for products that do not follow a descending schedule.
+func decrementInterval(product pgstore.Product) time.Duration {
+	if product.AuctionType != pgstore.AuctionTypeDutch || !product.DecrementIntervalSeconds.Valid {
+		return 0
+	}
+	return time.Duration(product.DecrementIntervalSeconds.Int32) * time.Second
+}
+
+// DutchPrice is the asking price of a dutch auction at the given instant. It
+// starts at the base price when the auction opens and drops by one decrement
+// per interval until it reaches the floor price.
+func DutchPrice(product pgstore.Product, at time.Time) money.Amount {
+	interval := decrementInterval(product)
+	if interval <= 0 || product.PriceDecrement == nil || product.FloorPrice == nil {
+		return product.Baseprice
+	}
+
+	steps := at.Sub(product.AuctionStart) / interval
+	if steps <= 0 {
+		return product.Baseprice
+	}
+
+	// Past this many steps the price is already on the floor, stopping here
+	// keeps the multiplication below from overflowing.
+	if maxSteps := (product.Baseprice-*product.FloorPrice) / *product.PriceDecrement + 1; money.Amount(steps) > maxSteps {
+		return *product.FloorPrice
+	}
+
+	return max(product.Baseprice-*product.PriceDecrement*money.Amount(steps), *product.FloorPrice)
+}
+
+// NextPriceDrop returns when the asking price of a dutch auction drops after
+// the given instant, or false once it has reached the floor price.
+func NextPriceDrop(product pgstore.Product, after time.Time) (time.Time, bool) {
+	interval := decrementInterval(product)
+	if interval <= 0 || product.FloorPrice == nil || DutchPrice(product, after) <= *product.FloorPrice {
+		return time.Time{}, false
+	}
+
+	steps := max(after.Sub(product.AuctionStart)/interval, 0) + 1
+	return product.AuctionStart.Add(steps * interval), true
+}
+
+// AcceptPrice sells a dutch auction to buyer_id at its current asking price.
+// The product row lock and the single-bid rule of the bids table make sure
+// only the first of several simultaneous accepts wins.
+func (bs *BidsService) AcceptPrice(ctx context.Context, product_id, buyer_id uuid.UUID) (AuctionResult, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, buyer_id)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeDutch {
+		return AuctionResult{}, ErrWrongAuctionType
+	}
+
+	bid, err := createBid(ctx, queries, pgstore.CreateBidParams{
+		ProductID: product_id,
+		BidderID:  buyer_id,
+		BidAmount: DutchPrice(product, time.Now()),
+	})
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	sale, err := queries.CreateSale(ctx, pgstore.CreateSaleParams{
+		ProductID:  product.ID,
+		BidID:      bid.ID,
+		BuyerID:    buyer_id,
+		SellerID:   product.SellerID,
+		FinalPrice: bid.BidAmount,
+	})
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := queries.CloseProduct(ctx, pgstore.CloseProductParams{ID: product.ID, IsSold: true}); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return AuctionResult{
+		Product:    product,
+		LeadingBid: bid,
+		Sale:       sale,
+		Sold:       true,
+	}, nil
+}
+
+var ErrSealedBidPlaced = errors.New("you have already placed your sealed bid")
+
+// IsSealed reports whether the bids on auctionType stay secret until it
+// closes.
+func IsSealed(auctionType pgstore.AuctionType) bool {
+	return auctionType == pgstore.AuctionTypeSealedFirstPrice || auctionType == pgstore.AuctionTypeSealedSecondPrice
+}
+
+// PlaceSealedBid records the only bid bidder_id gets in a sealed-bid auction,
+// or replaces it when revisions are allowed. Sealed bids are not compared with
+// each other until the auction is settled, they only have to reach the base
+// price.
+func (bs *BidsService) PlaceSealedBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Amount) (pgstore.Bid, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return pgstore.Bid{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, bidder_id)
+	if err != nil {
+		return pgstore.Bid{}, err
+	}
+
+	if !IsSealed(product.AuctionType) {
+		return pgstore.Bid{}, ErrWrongAuctionType
+	}
+
+	if amount < product.Baseprice {
+		return pgstore.Bid{}, &BidTooLowError{Minimum: product.Baseprice}
+	}
+
+	var bid pgstore.Bid
+	current, err := queries.GetBidByBidder(ctx, pgstore.GetBidByBidderParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+	})
+	switch {
+	case err == nil && !bs.config.SealedBidRevisions:
+		return pgstore.Bid{}, ErrSealedBidPlaced
+	case err == nil:
+		// A revised bid counts as placed now when breaking ties.
+		bid, err = queries.UpdateBidAmount(ctx, pgstore.UpdateBidAmountParams{
+			ID:        current.ID,
+			BidAmount: amount,
+		})
+	case errors.Is(err, pgx.ErrNoRows):
+		bid, err = createBid(ctx, queries, pgstore.CreateBidParams{
+			ProductID: product_id,
+			BidderID:  bidder_id,
+			BidAmount: amount,
+		})
+	}
+	if err != nil {
+		return pgstore.Bid{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.Bid{}, err
+	}
+
+	return bid, nil
+}
+
+// sealedPrice is what the winner of an auction pays. First-price auctions,
+// like every open auction, charge the winning bid. Second-price (Vickrey)
+// auctions charge the runner-up bid, or the base price without one, but never
+// less than the reserve price.
+func sealedPrice(product pgstore.Product, bids []pgstore.Bid, winningAmount money.Amount) money.Amount {
+	if product.AuctionType != pgstore.AuctionTypeSealedSecondPrice {
+		return winningAmount
+	}
+
+	price := product.Baseprice
+	if len(bids) > 1 {
+		price = bids[1].BidAmount
+	}
+	if product.ReservePrice != nil {
+		price = max(price, *product.ReservePrice)
+	}
+	return min(price, winningAmount)
+}
+
+var ErrAuctionAlreadyOpen = errors.New("the auction has already started, bid in its room instead")
+
+// PlacePreBid leaves an absentee bid on an auction that has not opened yet.
+// It becomes a maximum bid of an english auction, or the sealed bid of a
+// sealed-bid auction, once OpenAuction runs. Bidders may change their pre-bid
+// until then.
+func (bs *BidsService) PlacePreBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Amount) (pgstore.PreBid, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return pgstore.PreBid{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := queries.GetProductByIdForUpdate(ctx, product_id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.PreBid{}, ErrProductNotFound
+		}
+		return pgstore.PreBid{}, err
+	}
+
+	if product.ClosedAt.Valid {
+		return pgstore.PreBid{}, ErrAuctionClosed
+	}
+
+	if product.SellerID == bidder_id {
+		return pgstore.PreBid{}, ErrSelfBid
+	}
+
+	if !time.Now().Before(product.AuctionStart) {
+		return pgstore.PreBid{}, ErrAuctionAlreadyOpen
+	}
+
+	var minimum money.Amount
+	switch {
+	case product.AuctionType == pgstore.AuctionTypeEnglish:
+		minimum = bs.minimumNextBid(product, pgstore.Bid{}, false)
+	case IsSealed(product.AuctionType):
+		minimum = product.Baseprice
+	default:
+		return pgstore.PreBid{}, ErrWrongAuctionType
+	}
+
+	if amount < minimum {
+		return pgstore.PreBid{}, &BidTooLowError{Minimum: minimum}
+	}
+
+	preBid, err := queries.UpsertPreBid(ctx, pgstore.UpsertPreBidParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+		Amount:    amount,
+	})
+	if err != nil {
+		return pgstore.PreBid{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.PreBid{}, err
+	}
+
+	return preBid, nil
+}
+
+// OpenAuction applies the pre-bids of product_id when its auction starts and
+// returns the product as the room should see it. Pre-bids keep the time they
+// were last changed, so ties still go to whoever committed first, and they
+// are removed once applied so opening twice is harmless.
+func (bs *BidsService) OpenAuction(ctx context.Context, product_id uuid.UUID) (pgstore.Product, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockOpenProduct(ctx, queries, product_id)
+	if err != nil {
+		return pgstore.Product{}, err
+	}
+
+	switch {
+	case product.AuctionType == pgstore.AuctionTypeEnglish:
+		if err := queries.PromotePreBidsToMaxBids(ctx, product_id); err != nil {
+			return pgstore.Product{}, err
+		}
+
+		leadingBid, hasLeadingBid, err := getLeadingBid(ctx, queries, product)
+		if err != nil {
+			return pgstore.Product{}, err
+		}
+
+		if _, err := bs.resolveProxyBids(ctx, queries, product, leadingBid, hasLeadingBid); err != nil {
+			return pgstore.Product{}, err
+		}
+	case IsSealed(product.AuctionType):
+		if err := queries.PromotePreBidsToBids(ctx, product_id); err != nil {
+			return pgstore.Product{}, err
+		}
+	}
+
+	if err := queries.DeletePreBidsByProductId(ctx, product_id); err != nil {
+		return pgstore.Product{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.Product{}, err
+	}
+
+	return product, nil
+}
+
+var (
+	ErrNotProductSeller = errors.New("only the seller can manage this auction")
+	ErrCancelNotAllowed = errors.New("an auction with bids can not be cancelled this close to its end")
+	ErrNoBidToAccept    = errors.New("there is no bid to accept")
+)
+
+// lockSellerProduct loads the product of seller_id and holds a row lock on it
+// until the transaction ends.
+func lockSellerProduct(ctx context.Context, queries *pgstore.Queries, product_id, seller_id uuid.UUID) (pgstore.Product, error) {
+	product, err := queries.GetProductByIdForUpdate(ctx, product_id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.Product{}, ErrProductNotFound
+		}
+		return pgstore.Product{}, err
+	}
+
+	if product.SellerID != seller_id {
+		return pgstore.Product{}, ErrNotProductSeller
+	}
+
+	if product.ClosedAt.Valid {
+		return pgstore.Product{}, ErrAuctionClosed
+	}
+
+	return product, nil
+}
+
+// CancelAuction lets the seller call an auction off. Every bid is voided and
+// pending maximum bids and pre-bids are dropped. Auctions with bids can not be
+// cancelled within CancelCutoff of their deadline.
+func (bs *BidsService) CancelAuction(ctx context.Context, product_id, seller_id uuid.UUID) (AuctionResult, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockSellerProduct(ctx, queries, product_id, seller_id)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	leadingBid, hasLeadingBid, err := getLeadingBid(ctx, queries, product)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if hasLeadingBid && time.Until(product.AuctionEnd) < bs.config.CancelCutoff {
+		return AuctionResult{}, ErrCancelNotAllowed
+	}
+
+	if err := queries.VoidBidsByProductId(ctx, product_id); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := queries.DeleteMaxBidsByProductId(ctx, product_id); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := queries.DeletePreBidsByProductId(ctx, product_id); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := queries.CancelProduct(ctx, product_id); err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return AuctionResult{
+		Product:    product,
+		LeadingBid: leadingBid,
+		Cancelled:  true,
+	}, nil
+}
+
+// CloseAuctionEarly lets the seller end a running auction right away and
+// accept the bid leading it. The auction is settled as if its deadline had
+// passed, so the reserve price still applies.
+func (bs *BidsService) CloseAuctionEarly(ctx context.Context, product_id, seller_id uuid.UUID) (AuctionResult, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockSellerProduct(ctx, queries, product_id, seller_id)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if _, hasLeadingBid, err := getLeadingBid(ctx, queries, product); err != nil {
+		return AuctionResult{}, err
+	} else if !hasLeadingBid {
+		return AuctionResult{}, ErrNoBidToAccept
+	}
+
+	result, err := bs.settle(ctx, queries, product)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return result, nil
+}
+
+var ErrInvalidQuantity = errors.New("the quantity must be between 1 and the number of units on sale")
+
+// IsMultiUnit reports whether auctionType sells several identical units.
+func IsMultiUnit(auctionType pgstore.AuctionType) bool {
+	return auctionType == pgstore.AuctionTypeMultiUnitUniform || auctionType == pgstore.AuctionTypeMultiUnitDiscriminatory
+}
+
+// Allocation is the share of a multi-unit auction won by a bid.
+type Allocation struct {
+	Bid      pgstore.Bid
+	Quantity int32
+}
+
+// allocate hands the units of product to the best bids first, ties going to
+// the earliest one, so the last winning bid may get fewer units than it asked
+// for. bids must be sorted the way GetBidsByProductId returns them. The
+// clearing price is the lowest winning price per unit and soldOut reports
+// whether every unit has been claimed.
+func allocate(product pgstore.Product, bids []pgstore.Bid) (allocations []Allocation, clearingPrice money.Amount, soldOut bool) {
+	remaining := product.Quantity
+	for _, bid := range bids {
+		if remaining == 0 {
+			break
+		}
+		units := min(bid.Quantity, remaining)
+		allocations = append(allocations, Allocation{Bid: bid, Quantity: units})
+		clearingPrice = bid.BidAmount
+		remaining -= units
+	}
+	return allocations, clearingPrice, remaining == 0
+}
+
+// unitPrice is what the winner of allocation pays per unit: the clearing
+// price in uniform auctions, their own bid in discriminatory ones.
+func unitPrice(product pgstore.Product, allocation Allocation, clearingPrice money.Amount) money.Amount {
+	if product.AuctionType == pgstore.AuctionTypeMultiUnitUniform {
+		return clearingPrice
+	}
+	return allocation.Bid.BidAmount
+}
+
+// PlaceMultiUnitBid asks for quantity units of a multi-unit auction at amount
+// per unit. Once every unit is claimed a bid has to beat the clearing price by
+// one increment, taking units away from the lowest winning bids.
+func (bs *BidsService) PlaceMultiUnitBid(ctx context.Context, product_id, bidder_id uuid.UUID, amount money.Amount, quantity int32) (BidOutcome, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockBiddableProduct(ctx, queries, product_id, bidder_id)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	if !IsMultiUnit(product.AuctionType) {
+		return BidOutcome{}, ErrWrongAuctionType
+	}
+
+	if quantity < 1 || quantity > product.Quantity {
+		return BidOutcome{}, ErrInvalidQuantity
+	}
+
+	bids, err := queries.GetBidsByProductId(ctx, product_id)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	price := product.Baseprice
+	if _, clearingPrice, soldOut := allocate(product, bids); soldOut {
+		price = clearingPrice
+	}
+
+	if minimum := price + bs.incrementsFor(product).IncrementFor(price); amount < minimum {
+		return BidOutcome{}, &BidTooLowError{Minimum: minimum}
+	}
+
+	bid, err := queries.CreateBidForQuantity(ctx, pgstore.CreateBidForQuantityParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+		BidAmount: amount,
+		Quantity:  quantity,
+	})
+	if err != nil {
+		return BidOutcome{}, bidError(err)
+	}
+
+	bids, err = queries.GetBidsByProductId(ctx, product_id)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+
+	outcome, err := bs.newOutcome(ctx, queries, product, bid, true)
+	if err != nil {
+		return BidOutcome{}, err
+	}
+	_, outcome.ClearingPrice, _ = allocate(product, bids)
+
+	if err := tx.Commit(ctx); err != nil {
+		return BidOutcome{}, err
+	}
+
+	return outcome, nil
+}
+
+// settleUnits closes a multi-unit auction, recording a sale for every winning
+// bid. Uniform pricing charges every winner the clearing price, discriminatory
+// pricing charges each winner their own bid.
+func (bs *BidsService) settleUnits(ctx context.Context, queries *pgstore.Queries, product pgstore.Product) (AuctionResult, error) {
+	bids, err := queries.GetBidsByProductId(ctx, product.ID)
+	if err != nil {
+		return AuctionResult{}, err
+	}
+
+	allocations, clearingPrice, _ := allocate(product, bids)
+
+	result := AuctionResult{Product: product, ClearingPrice: clearingPrice}
+	if len(bids) > 0 {
+		result.LeadingBid = bids[0]
+	}
+
+	for _, allocation := range allocations {
+		sale, err := queries.CreateSaleForQuantity(ctx, pgstore.CreateSaleForQuantityParams{
+			ProductID:  product.ID,
+			BidID:      allocation.Bid.ID,
+			BuyerID:    allocation.Bid.BidderID,
+			SellerID:   product.SellerID,
+			FinalPrice: unitPrice(product, allocation, clearingPrice) * money.Amount(allocation.Quantity),
+			Quantity:   allocation.Quantity,
+		})
+		if err != nil {
+			return AuctionResult{}, err
+		}
+		result.Sales = append(result.Sales, sale)
+	}
+	result.Sold = len(result.Sales) > 0
+
+	if err := queries.CloseProduct(ctx, pgstore.CloseProductParams{ID: product.ID, IsSold: result.Sold}); err != nil {
+		return AuctionResult{}, err
+	}
+
+	return result, nil
+}
+
+var (
+	ErrNoBidToRetract          = errors.New("you have no bid to retract on this auction")
+	ErrRetractionWindowPassed  = errors.New("this bid was placed too long ago to be retracted")
+	ErrRetractionTooLate       = errors.New("bids can not be retracted this close to the end of the auction")
+	ErrRetractionLimitExceeded = errors.New("you have reached your monthly bid retraction limit")
+)
+
+// RetractionOutcome is the state of an auction once a bid was retracted.
+type RetractionOutcome struct {
+	Retracted pgstore.Bid
+	// Leading is the bid leading the auction among the remaining ones, if
+	// HasLeading. Price is its amount, or the base price without bids.
+	Leading    pgstore.Bid
+	HasLeading bool
+	Price      money.Amount
+	ReserveMet *bool
+}
+
+// RetractBid withdraws the latest bid of bidder_id, for instance one with a
+// typo in its amount. Only bids placed within RetractionWindow can be
+// retracted, never within RetractionCutoff of the deadline, and at most
+// MonthlyRetractionLimit times per calendar month. A retracted english bid
+// also drops the bidder's maximum bid so the proxy does not bid again.
+func (bs *BidsService) RetractBid(ctx context.Context, product_id, bidder_id uuid.UUID) (RetractionOutcome, error) {
+	tx, err := bs.pool.Begin(ctx)
+	if err != nil {
+		return RetractionOutcome{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := bs.queries.WithTx(tx)
+
+	product, err := lockOpenProduct(ctx, queries, product_id)
+	if err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	if product.AuctionType != pgstore.AuctionTypeEnglish && product.AuctionType != pgstore.AuctionTypeReverse {
+		return RetractionOutcome{}, ErrWrongAuctionType
+	}
+
+	if time.Until(product.AuctionEnd) < bs.config.RetractionCutoff {
+		return RetractionOutcome{}, ErrRetractionTooLate
+	}
+
+	bid, err := queries.GetLatestBidByBidder(ctx, pgstore.GetLatestBidByBidderParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+	})
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RetractionOutcome{}, ErrNoBidToRetract
+		}
+		return RetractionOutcome{}, err
+	}
+
+	if time.Since(bid.CreatedAt) > bs.config.RetractionWindow {
+		return RetractionOutcome{}, ErrRetractionWindowPassed
+	}
+
+	// The product lock does not cover retractions of the same bidder on
+	// other products, they could all count the same number of retractions.
+	if err := queries.LockBidderRetractions(ctx, bidder_id); err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	retractions, err := queries.CountRetractionsByBidderSince(ctx, pgstore.CountRetractionsByBidderSinceParams{
+		BidderID:    bidder_id,
+		RetractedAt: pgtype.Timestamptz{Time: monthStart, Valid: true},
+	})
+	if err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	if retractions >= int64(bs.config.MonthlyRetractionLimit) {
+		return RetractionOutcome{}, ErrRetractionLimitExceeded
+	}
+
+	retracted, err := queries.RetractBid(ctx, bid.ID)
+	if err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	if err := queries.DeleteMaxBidByBidder(ctx, pgstore.DeleteMaxBidByBidderParams{
+		ProductID: product_id,
+		BidderID:  bidder_id,
+	}); err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	leadingBid, hasLeadingBid, err := getLeadingBid(ctx, queries, product)
+	if err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	outcome := RetractionOutcome{
+		Retracted:  retracted,
+		Leading:    leadingBid,
+		HasLeading: hasLeadingBid,
+		Price:      product.Baseprice,
+	}
+	if hasLeadingBid {
+		outcome.Price = leadingBid.BidAmount
+	}
+	outcome.ReserveMet = reserveMet(product, outcome.Price)
+
+	if err := tx.Commit(ctx); err != nil {
+		return RetractionOutcome{}, err
+	}
+
+	return outcome, nil
 }
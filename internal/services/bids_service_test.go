@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gobid/internal/money"
+	"gobid/internal/store/pgstore"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testPool connects to the database named by GOBID_TEST_DATABASE_URL, which
+// must be migrated with tern. Tests that need it are skipped without one.
+func testPool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	url := os.Getenv("GOBID_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("GOBID_TEST_DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+
+	if err := pool.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return pool
+}
+
+// unreachablePool never connects, every query through it fails.
+func unreachablePool(t *testing.T) *pgxpool.Pool {
+	t.Helper()
+
+	pool, err := pgxpool.New(context.Background(), "postgres://gobid@127.0.0.1:1/gobid?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(pool.Close)
+	return pool
+}
+
+func createTestUser(t *testing.T, us UserService) uuid.UUID {
+	t.Helper()
+
+	name := "test-" + uuid.NewString()
+	id, err := us.CreateUser(context.Background(), name, name+"@example.com", "password123", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestPlaceBidConcurrently(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	users := NewUserService(pool)
+	products := NewProductsService(pool)
+	bids := NewBidsService(pool, DefaultAuctionConfig())
+
+	seller := createTestUser(t, users)
+	bidders := make([]uuid.UUID, 8)
+	for i := range bidders {
+		bidders[i] = createTestUser(t, users)
+	}
+
+	const baseprice = money.Amount(100_00)
+	product, err := products.CreateProduct(ctx, seller, NewProduct{
+		ProductName:   "Concurrent bidding",
+		Baseprice:     baseprice,
+		Currency:      money.USD,
+		AuctionEnd:    time.Now().Add(time.Hour),
+		AuctionType:   pgstore.AuctionTypeEnglish,
+		BidIncrements: IncrementTable{{Increment: 1_00}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every amount is placed by three calls at once, so at most one of them
+	// can win. Calls are started in increasing amount order but at most 16
+	// run at the same time, which leaves plenty of them strictly ordered.
+	type call struct {
+		amount     money.Amount
+		start, end time.Time
+		err        error
+	}
+	calls := make([]call, 300)
+	slots := make(chan struct{}, 16)
+
+	var wg sync.WaitGroup
+	for i := range calls {
+		calls[i].amount = baseprice + money.Amount(i/3+1)*10_00
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(c *call, bidder uuid.UUID) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			c.start = time.Now()
+			_, c.err = bids.PlaceBid(ctx, product.ID, bidder, c.amount)
+			c.end = time.Now()
+		}(&calls[i], bidders[i%len(bidders)])
+	}
+	wg.Wait()
+
+	var accepted []call
+	for _, c := range calls {
+		switch {
+		case c.err == nil:
+			accepted = append(accepted, c)
+		case !errors.Is(c.err, ErrBidIsTooLow):
+			t.Fatalf("bid of %s refused with %v, want %v", c.amount, c.err, ErrBidIsTooLow)
+		}
+	}
+	if len(accepted) == 0 {
+		t.Fatal("no bid was accepted")
+	}
+
+	// A bid that was accepted before another one started has to be lower.
+	for _, a := range accepted {
+		for _, b := range accepted {
+			if a.end.Before(b.start) && a.amount >= b.amount {
+				t.Errorf("bid of %s accepted after a bid of %s", b.amount, a.amount)
+			}
+		}
+	}
+
+	placed, err := bids.queries.GetBidsByProductId(ctx, product.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(placed) != len(accepted) {
+		t.Fatalf("%d bids stored, %d accepted", len(placed), len(accepted))
+	}
+
+	// Bids are ordered by amount, the highest first.
+	seen := make(map[money.Amount]bool)
+	for _, bid := range placed {
+		if seen[bid.BidAmount] {
+			t.Errorf("two bids of %s were accepted", bid.BidAmount)
+		}
+		seen[bid.BidAmount] = true
+	}
+	if len(placed) > 1 && placed[0].BidAmount == placed[1].BidAmount {
+		t.Errorf("more than one bid is the highest at %s", placed[0].BidAmount)
+	}
+
+	highest := accepted[0].amount
+	for _, c := range accepted {
+		highest = max(highest, c.amount)
+	}
+	if placed[0].BidAmount != highest {
+		t.Errorf("highest stored bid is %s, want %s", placed[0].BidAmount, highest)
+	}
+}
+
+func TestRetractBidConcurrently(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	users := NewUserService(pool)
+	products := NewProductsService(pool)
+	config := DefaultAuctionConfig()
+	bids := NewBidsService(pool, config)
+
+	seller := createTestUser(t, users)
+	bidder := createTestUser(t, users)
+
+	// The bidder retracts a bid on more products than the monthly limit
+	// allows, all at once.
+	productIDs := make([]uuid.UUID, 4*config.MonthlyRetractionLimit)
+	for i := range productIDs {
+		product, err := products.CreateProduct(ctx, seller, NewProduct{
+			ProductName:   "Concurrent retraction",
+			Baseprice:     100_00,
+			Currency:      money.USD,
+			AuctionEnd:    time.Now().Add(24 * time.Hour),
+			AuctionType:   pgstore.AuctionTypeEnglish,
+			BidIncrements: IncrementTable{{Increment: 1_00}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := bids.PlaceBid(ctx, product.ID, bidder, 110_00); err != nil {
+			t.Fatal(err)
+		}
+		productIDs[i] = product.ID
+	}
+
+	errs := make([]error, len(productIDs))
+	var wg sync.WaitGroup
+	for i, id := range productIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = bids.RetractBid(ctx, id, bidder)
+		}()
+	}
+	wg.Wait()
+
+	retracted := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			retracted++
+		case !errors.Is(err, ErrRetractionLimitExceeded):
+			t.Fatalf("retraction refused with %v, want %v", err, ErrRetractionLimitExceeded)
+		}
+	}
+	if retracted != config.MonthlyRetractionLimit {
+		t.Errorf("%d bids retracted, want the limit of %d", retracted, config.MonthlyRetractionLimit)
+	}
+}
+
+func dutchProduct(start time.Time) pgstore.Product {
+	decrement := money.Amount(10_00)
+	floor := money.Amount(35_00)
+	return pgstore.Product{
+		AuctionType:              pgstore.AuctionTypeDutch,
+		AuctionStart:             start,
+		Baseprice:                100_00,
+		PriceDecrement:           &decrement,
+		FloorPrice:               &floor,
+		DecrementIntervalSeconds: pgtype.Int4{Int32: 60, Valid: true},
+	}
+}
+
+func TestDutchPrice(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	product := dutchProduct(start)
+
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  money.Amount
+	}{
+		{name: "before the start", after: -time.Hour, want: 100_00},
+		{name: "at the start", after: 0, want: 100_00},
+		{name: "just before the first step", after: time.Minute - time.Nanosecond, want: 100_00},
+		{name: "first step", after: time.Minute, want: 90_00},
+		{name: "last step above the floor", after: 6 * time.Minute, want: 40_00},
+		{name: "step below the floor", after: 7 * time.Minute, want: 35_00},
+		{name: "long after", after: 100 * 365 * 24 * time.Hour, want: 35_00},
+	}
+
+	for _, tt := range tests {
+		if got := DutchPrice(product, start.Add(tt.after)); got != tt.want {
+			t.Errorf("%s: price is %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	english := product
+	english.AuctionType = pgstore.AuctionTypeEnglish
+	if got := DutchPrice(english, start.Add(time.Hour)); got != english.Baseprice {
+		t.Errorf("english auction price is %s, want its base price", got)
+	}
+}
+
+func TestNextPriceDrop(t *testing.T) {
+	start := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	product := dutchProduct(start)
+
+	tests := []struct {
+		name   string
+		after  time.Duration
+		want   time.Duration
+		noDrop bool
+	}{
+		{name: "before the start", after: -time.Hour, want: time.Minute},
+		{name: "at the start", after: 0, want: time.Minute},
+		{name: "on a step", after: time.Minute, want: 2 * time.Minute},
+		{name: "between steps", after: 6*time.Minute + 30*time.Second, want: 7 * time.Minute},
+		{name: "on the floor", after: 7 * time.Minute, noDrop: true},
+	}
+
+	for _, tt := range tests {
+		at, ok := NextPriceDrop(product, start.Add(tt.after))
+		switch {
+		case tt.noDrop && ok:
+			t.Errorf("%s: price drops at %s, want no drop", tt.name, at)
+		case !tt.noDrop && (!ok || !at.Equal(start.Add(tt.want))):
+			t.Errorf("%s: price drops at %s, %v, want %s", tt.name, at, ok, start.Add(tt.want))
+		}
+	}
+}
+
+func TestSealedPrice(t *testing.T) {
+	bids := func(amounts ...money.Amount) []pgstore.Bid {
+		bids := make([]pgstore.Bid, len(amounts))
+		for i, amount := range amounts {
+			bids[i] = pgstore.Bid{BidderID: uuid.New(), BidAmount: amount}
+		}
+		return bids
+	}
+	reserve := func(amount money.Amount) *money.Amount { return &amount }
+
+	tests := []struct {
+		name        string
+		auctionType pgstore.AuctionType
+		reserve     *money.Amount
+		bids        []pgstore.Bid
+		want        money.Amount
+	}{
+		{name: "first price", auctionType: pgstore.AuctionTypeSealedFirstPrice, bids: bids(150_00, 120_00), want: 150_00},
+		{name: "second price", auctionType: pgstore.AuctionTypeSealedSecondPrice, bids: bids(150_00, 120_00, 110_00), want: 120_00},
+		{name: "tie", auctionType: pgstore.AuctionTypeSealedSecondPrice, bids: bids(150_00, 150_00, 110_00), want: 150_00},
+		{name: "single bid", auctionType: pgstore.AuctionTypeSealedSecondPrice, bids: bids(150_00), want: 100_00},
+		{name: "reserve above the runner-up", auctionType: pgstore.AuctionTypeSealedSecondPrice, reserve: reserve(130_00), bids: bids(150_00, 120_00), want: 130_00},
+		{name: "reserve below the runner-up", auctionType: pgstore.AuctionTypeSealedSecondPrice, reserve: reserve(110_00), bids: bids(150_00, 120_00), want: 120_00},
+		{name: "reserve above the winning bid", auctionType: pgstore.AuctionTypeSealedSecondPrice, reserve: reserve(200_00), bids: bids(150_00, 120_00), want: 150_00},
+	}
+
+	for _, tt := range tests {
+		product := pgstore.Product{AuctionType: tt.auctionType, Baseprice: 100_00, ReservePrice: tt.reserve}
+		if got := sealedPrice(product, tt.bids, tt.bids[0].BidAmount); got != tt.want {
+			t.Errorf("%s: winner pays %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	bid := func(amount money.Amount, quantity int32) pgstore.Bid {
+		return pgstore.Bid{ID: uuid.New(), BidderID: uuid.New(), BidAmount: amount, Quantity: quantity}
+	}
+	// Sorted the way GetBidsByProductId returns them, the best bid first and
+	// the earliest of a tie before the other.
+	bids := []pgstore.Bid{bid(30_00, 2), bid(25_00, 3), bid(25_00, 2), bid(20_00, 4)}
+
+	tests := []struct {
+		name          string
+		units         int32
+		bids          []pgstore.Bid
+		want          []int32
+		clearingPrice money.Amount
+		soldOut       bool
+	}{
+		{name: "no bids", units: 5},
+		{name: "fewer units asked than on sale", units: 20, bids: bids, want: []int32{2, 3, 2, 4}, clearingPrice: 20_00},
+		{name: "every unit asked", units: 11, bids: bids, want: []int32{2, 3, 2, 4}, clearingPrice: 20_00, soldOut: true},
+		{name: "last winner gets a part", units: 6, bids: bids, want: []int32{2, 3, 1}, clearingPrice: 25_00, soldOut: true},
+		{name: "earliest of a tie first", units: 4, bids: bids, want: []int32{2, 2}, clearingPrice: 25_00, soldOut: true},
+		{name: "best bid takes everything", units: 2, bids: bids, want: []int32{2}, clearingPrice: 30_00, soldOut: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := pgstore.Product{AuctionType: pgstore.AuctionTypeMultiUnitUniform, Quantity: tt.units}
+			allocations, clearingPrice, soldOut := allocate(product, tt.bids)
+
+			if len(allocations) != len(tt.want) {
+				t.Fatalf("%d bids won, want %d", len(allocations), len(tt.want))
+			}
+			for i, allocation := range allocations {
+				if allocation.Bid.ID != tt.bids[i].ID || allocation.Quantity != tt.want[i] {
+					t.Errorf("bid %d won %d units, want %d", i, allocation.Quantity, tt.want[i])
+				}
+			}
+			if clearingPrice != tt.clearingPrice || soldOut != tt.soldOut {
+				t.Errorf("clearing price %s, sold out %v, want %s and %v", clearingPrice, soldOut, tt.clearingPrice, tt.soldOut)
+			}
+		})
+	}
+}
+
+func TestUnitPrice(t *testing.T) {
+	allocation := Allocation{Bid: pgstore.Bid{BidAmount: 30_00, Quantity: 2}, Quantity: 2}
+
+	uniform := pgstore.Product{AuctionType: pgstore.AuctionTypeMultiUnitUniform}
+	if got := unitPrice(uniform, allocation, 25_00); got != 25_00 {
+		t.Errorf("uniform auction winner pays %s a unit, want the clearing price", got)
+	}
+
+	discriminatory := pgstore.Product{AuctionType: pgstore.AuctionTypeMultiUnitDiscriminatory}
+	if got := unitPrice(discriminatory, allocation, 25_00); got != 30_00 {
+		t.Errorf("discriminatory auction winner pays %s a unit, want their bid", got)
+	}
+}
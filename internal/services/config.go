@@ -0,0 +1,49 @@
+package services
+
+import "time"
+
+// AuctionConfig holds the rules shared by every auction. Products may
+// override some of them when they are created.
+type AuctionConfig struct {
+	BidIncrements IncrementTable
+	// BuyNowThresholdPercent is the share of the buy-now price the highest
+	// bid has to reach for the buy-now option to disappear.
+	BuyNowThresholdPercent int
+	// A bid placed within SoftCloseWindow of the deadline pushes it out to
+	// SoftCloseExtension after the bid. A zero window disables soft closing.
+	SoftCloseWindow    time.Duration
+	SoftCloseExtension time.Duration
+	// SealedBidRevisions lets bidders replace their sealed bid before the
+	// auction closes.
+	SealedBidRevisions bool
+	// Sellers can not cancel an auction that has bids once it is within
+	// CancelCutoff of its deadline.
+	CancelCutoff time.Duration
+	// Bidders may retract a bid within RetractionWindow of placing it,
+	// unless the auction is within RetractionCutoff of its deadline, and at
+	// most MonthlyRetractionLimit times per calendar month.
+	RetractionWindow       time.Duration
+	RetractionCutoff       time.Duration
+	MonthlyRetractionLimit int
+	// BidderHandleKey signs the pseudonyms bidders are shown under in bid
+	// histories, see BidsService.BidderHandle.
+	BidderHandleKey []byte
+	// MaxConnectionsPerUser caps the connections a user may keep open to a
+	// room, such as several tabs or devices. Zero lifts the cap.
+	MaxConnectionsPerUser int
+}
+
+func DefaultAuctionConfig() AuctionConfig {
+	return AuctionConfig{
+		BidIncrements:          DefaultIncrementTable,
+		BuyNowThresholdPercent: 50,
+		SoftCloseWindow:        5 * time.Minute,
+		SoftCloseExtension:     5 * time.Minute,
+		SealedBidRevisions:     true,
+		CancelCutoff:           12 * time.Hour,
+		RetractionWindow:       10 * time.Minute,
+		RetractionCutoff:       time.Hour,
+		MonthlyRetractionLimit: 3,
+		MaxConnectionsPerUser:  5,
+	}
+}
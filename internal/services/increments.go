@@ -0,0 +1,89 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gobid/internal/money"
+	"strings"
+)
+
+// IncrementTier applies Increment to every price below UpTo. The last tier
+// of a table leaves UpTo empty and covers every price above the others.
+type IncrementTier struct {
+	UpTo      money.Amount `json:"up_to,omitempty"`
+	Increment money.Amount `json:"increment"`
+}
+
+// IncrementTable is a list of tiers ordered by UpTo that decides how much a
+// bid must raise the current price.
+type IncrementTable []IncrementTier
+
+var DefaultIncrementTable = IncrementTable{
+	{UpTo: 50_00, Increment: 1_00},
+	{UpTo: 500_00, Increment: 5_00},
+	{UpTo: 5_000_00, Increment: 25_00},
+	{Increment: 100_00},
+}
+
+var ErrInvalidIncrementTable = errors.New("increment tiers must be ordered by up_to, have positive increments and end with an open tier")
+
+func (t IncrementTable) Validate() error {
+	if len(t) == 0 {
+		return ErrInvalidIncrementTable
+	}
+
+	var previous money.Amount
+	for i, tier := range t {
+		last := i == len(t)-1
+		if tier.Increment <= 0 || (last && tier.UpTo != 0) || (!last && tier.UpTo <= previous) {
+			return ErrInvalidIncrementTable
+		}
+		previous = tier.UpTo
+	}
+
+	return nil
+}
+
+// IncrementFor returns the increment that applies on top of price.
+func (t IncrementTable) IncrementFor(price money.Amount) money.Amount {
+	for _, tier := range t {
+		if tier.UpTo == 0 || price < tier.UpTo {
+			return tier.Increment
+		}
+	}
+	return DefaultIncrementTable.IncrementFor(price)
+}
+
+// ParseIncrementTable reads a table written as "up_to:increment" pairs
+// separated by commas, using "*" for the open tier, e.g.
+// "50.00:1.00,500.00:5.00,*:25.00".
+func ParseIncrementTable(s string) (IncrementTable, error) {
+	var table IncrementTable
+
+	for _, pair := range strings.Split(s, ",") {
+		rawUpTo, rawIncrement, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid increment tier %q", pair)
+		}
+
+		var tier IncrementTier
+		var err error
+
+		if rawUpTo != "*" {
+			if tier.UpTo, err = money.Parse(rawUpTo); err != nil {
+				return nil, fmt.Errorf("invalid increment tier %q: %w", pair, err)
+			}
+		}
+		if tier.Increment, err = money.Parse(rawIncrement); err != nil {
+			return nil, fmt.Errorf("invalid increment tier %q: %w", pair, err)
+		}
+
+		table = append(table, tier)
+	}
+
+	if err := table.Validate(); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
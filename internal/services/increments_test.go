@@ -0,0 +1,90 @@
+package services
+
+import (
+	"errors"
+	"gobid/internal/money"
+	"testing"
+)
+
+func TestIncrementTableValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		table IncrementTable
+		valid bool
+	}{
+		{name: "default", table: DefaultIncrementTable, valid: true},
+		{name: "single open tier", table: IncrementTable{{Increment: 1_00}}, valid: true},
+		{name: "empty", table: IncrementTable{}},
+		{name: "unsorted", table: IncrementTable{{UpTo: 500_00, Increment: 5_00}, {UpTo: 50_00, Increment: 1_00}, {Increment: 25_00}}},
+		{name: "repeated bound", table: IncrementTable{{UpTo: 50_00, Increment: 1_00}, {UpTo: 50_00, Increment: 5_00}, {Increment: 25_00}}},
+		{name: "zero step", table: IncrementTable{{UpTo: 50_00, Increment: 0}, {Increment: 5_00}}},
+		{name: "negative step", table: IncrementTable{{Increment: -1_00}}},
+		{name: "gap above the last bound", table: IncrementTable{{UpTo: 50_00, Increment: 1_00}, {UpTo: 500_00, Increment: 5_00}}},
+		{name: "open tier first", table: IncrementTable{{Increment: 1_00}, {UpTo: 50_00, Increment: 5_00}, {Increment: 25_00}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.table.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("got %v, want a valid table", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidIncrementTable) {
+				t.Errorf("got %v, want %v", err, ErrInvalidIncrementTable)
+			}
+		})
+	}
+}
+
+func TestIncrementFor(t *testing.T) {
+	tests := []struct {
+		price money.Amount
+		want  money.Amount
+	}{
+		{price: 0, want: 1_00},
+		{price: 49_99, want: 1_00},
+		{price: 50_00, want: 5_00},
+		{price: 499_99, want: 5_00},
+		{price: 500_00, want: 25_00},
+		{price: 5_000_00, want: 100_00},
+		{price: 1_000_000_00, want: 100_00},
+	}
+
+	for _, tt := range tests {
+		if got := DefaultIncrementTable.IncrementFor(tt.price); got != tt.want {
+			t.Errorf("increment on %s is %s, want %s", tt.price, got, tt.want)
+		}
+	}
+}
+
+func TestParseIncrementTable(t *testing.T) {
+	table, err := ParseIncrementTable("50.00:1.00, 500:5, *:25.00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IncrementTable{{UpTo: 50_00, Increment: 1_00}, {UpTo: 500_00, Increment: 5_00}, {Increment: 25_00}}
+	if len(table) != len(want) {
+		t.Fatalf("got %v, want %v", table, want)
+	}
+	for i := range want {
+		if table[i] != want[i] {
+			t.Errorf("tier %d is %v, want %v", i, table[i], want[i])
+		}
+	}
+
+	for _, s := range []string{
+		"",
+		"50.00:1.00",
+		"500:5,50:1,*:25",
+		"50:0,*:1",
+		"50:1,50:2,*:5",
+		"*:1,50:2,*:5",
+		"50-1,*:5",
+		"50:1.005,*:5",
+		"fifty:1,*:5",
+	} {
+		if table, err := ParseIncrementTable(s); err == nil {
+			t.Errorf("%q parsed as %v, want an error", s, table)
+		}
+	}
+}
@@ -2,12 +2,15 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"gobid/internal/money"
 	"gobid/internal/store/pgstore"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -23,26 +26,78 @@ func NewProductsService(pool *pgxpool.Pool) ProductsService {
 	}
 }
 
-func (ps *ProductsService) CreateProduct(
-	ctx context.Context,
-	sellerId uuid.UUID,
-	product_name, description string,
-	baseprice float64,
-	auctionEnd time.Time,
-) (uuid.UUID, error) {
-	id, err := ps.queries.CreateProduct(ctx, pgstore.CreateProductParams{
-		SellerID:    sellerId,
-		ProductName: product_name,
-		Description: description,
-		Baseprice:   baseprice,
-		AuctionEnd:  auctionEnd,
+// NewProduct is everything a seller provides when listing a product. A zero
+// AuctionStart opens bidding right away and a zero Quantity lists one unit.
+type NewProduct struct {
+	ProductName   string
+	Description   string
+	Baseprice     money.Amount
+	Currency      money.Currency
+	AuctionStart  time.Time
+	AuctionEnd    time.Time
+	AuctionType   pgstore.AuctionType
+	BidIncrements IncrementTable
+	ReservePrice  *money.Amount
+	BuyNowPrice   *money.Amount
+	Quantity      int32
+
+	// Dutch auctions drop their price from Baseprice by PriceDecrement every
+	// DecrementInterval until they reach FloorPrice.
+	FloorPrice        *money.Amount
+	PriceDecrement    *money.Amount
+	DecrementInterval time.Duration
+}
+
+func (ps *ProductsService) CreateProduct(ctx context.Context, sellerId uuid.UUID, p NewProduct) (pgstore.Product, error) {
+	return createProduct(ctx, ps.queries, sellerId, p)
+}
+
+// createProduct lists p on behalf of sellerId, queries may be bound to a
+// transaction.
+func createProduct(ctx context.Context, queries *pgstore.Queries, sellerId uuid.UUID, p NewProduct) (pgstore.Product, error) {
+	var rawIncrements []byte
+	if len(p.BidIncrements) > 0 {
+		var err error
+		if rawIncrements, err = json.Marshal(p.BidIncrements); err != nil {
+			return pgstore.Product{}, err
+		}
+	}
+
+	auctionStart := p.AuctionStart
+	if auctionStart.IsZero() {
+		auctionStart = time.Now()
+	}
+
+	quantity := max(p.Quantity, 1)
+
+	var decrementInterval pgtype.Int4
+	if p.DecrementInterval > 0 {
+		decrementInterval = pgtype.Int4{Int32: int32(p.DecrementInterval / time.Second), Valid: true}
+	}
+
+	product, err := queries.CreateProduct(ctx, pgstore.CreateProductParams{
+		SellerID:                 sellerId,
+		ProductName:              p.ProductName,
+		Description:              p.Description,
+		Baseprice:                p.Baseprice,
+		AuctionEnd:               p.AuctionEnd,
+		Currency:                 p.Currency,
+		BidIncrements:            rawIncrements,
+		ReservePrice:             p.ReservePrice,
+		BuyNowPrice:              p.BuyNowPrice,
+		AuctionType:              p.AuctionType,
+		FloorPrice:               p.FloorPrice,
+		PriceDecrement:           p.PriceDecrement,
+		DecrementIntervalSeconds: decrementInterval,
+		AuctionStart:             auctionStart,
+		Quantity:                 quantity,
 	})
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return pgstore.Product{}, err
 	}
 
-	return id, nil
+	return product, nil
 }
 
 var ErrProductNotFound = errors.New("product not found")
@@ -59,3 +114,13 @@ func (ps *ProductsService) GetProductByID(ctx context.Context, productId uuid.UU
 
 	return product, nil
 }
+
+func (ps *ProductsService) ListOpenProducts(ctx context.Context) ([]pgstore.Product, error) {
+	products, err := ps.queries.ListOpenProducts(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"gobid/internal/money"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Clients pick the format of their messages through the websocket
+// subprotocol. ProtocolV1 sends Message as is, with integer kinds, and is
+// what clients asking for no subprotocol get. ProtocolV2 wraps messages in
+// an Envelope.
+const (
+	ProtocolV1 = "gobid.v1"
+	ProtocolV2 = "gobid.v2"
+)
+
+// Subprotocols lists the protocols a room speaks, the preferred one first.
+var Subprotocols = []string{ProtocolV2, ProtocolV1}
+
+// messageTypes names every MessageKind in the ProtocolV2 envelope. Unlike
+// the kinds themselves the names never depend on declaration order.
+var messageTypes = map[MessageKind]string{
+	PlaceBid:                     "place_bid",
+	SuccessfullyPlacedBid:        "bid_placed",
+	FailedToPlaceBid:             "bid_failed",
+	InvalidJSON:                  "invalid_message",
+	NewBidPlaced:                 "new_bid",
+	AuctionFinished:              "auction_finished",
+	AuctionWon:                   "auction_won",
+	AuctionSold:                  "auction_sold",
+	AuctionUnsold:                "auction_unsold",
+	PlaceMaxBid:                  "place_max_bid",
+	SuccessfullyPlacedMaxBid:     "max_bid_placed",
+	BuyNow:                       "buy_now",
+	SuccessfullyBoughtNow:        "bought_now",
+	FailedToBuyNow:               "buy_now_failed",
+	AuctionBoughtNow:             "auction_bought_now",
+	AuctionExtended:              "auction_extended",
+	AcceptPrice:                  "accept_price",
+	SuccessfullyAcceptedPrice:    "price_accepted",
+	FailedToAcceptPrice:          "accept_price_failed",
+	PriceDropped:                 "price_dropped",
+	BidsRevealed:                 "bids_revealed",
+	CancelAuction:                "cancel_auction",
+	CloseAuctionEarly:            "close_auction_early",
+	SuccessfullyCancelledAuction: "auction_cancel_accepted",
+	SuccessfullyClosedAuction:    "auction_close_accepted",
+	FailedToCloseAuction:         "close_auction_failed",
+	AuctionCancelled:             "auction_cancelled",
+	FailedToReachLot:             "lot_unreachable",
+	CurrentLotChanged:            "current_lot_changed",
+	EventFinished:                "event_finished",
+	RetractBid:                   "retract_bid",
+	SuccessfullyRetractedBid:     "bid_retracted_by_you",
+	FailedToRetractBid:           "retract_bid_failed",
+	BidRetracted:                 "bid_retracted",
+	AuctionSnapshot:              "auction_snapshot",
+	TooManyConnections:           "too_many_connections",
+}
+
+// requestKinds are the messages clients may send, by type.
+var requestKinds = map[string]MessageKind{
+	messageTypes[PlaceBid]:          PlaceBid,
+	messageTypes[PlaceMaxBid]:       PlaceMaxBid,
+	messageTypes[BuyNow]:            BuyNow,
+	messageTypes[AcceptPrice]:       AcceptPrice,
+	messageTypes[CancelAuction]:     CancelAuction,
+	messageTypes[CloseAuctionEarly]: CloseAuctionEarly,
+	messageTypes[RetractBid]:        RetractBid,
+}
+
+func (k MessageKind) isError() bool {
+	switch k {
+	case FailedToPlaceBid, InvalidJSON, FailedToBuyNow, FailedToAcceptPrice, FailedToCloseAuction, FailedToReachLot,
+		FailedToRetractBid, TooManyConnections:
+		return true
+	}
+	return false
+}
+
+var ErrUnknownMessageType = errors.New("unknown message type")
+
+// ErrorCode is the stable code clients can tell err apart by, such as
+// bid_too_low. Errors the client can do nothing about are internal_error.
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrBidIsTooLow):
+		return "bid_too_low"
+	case errors.Is(err, ErrBidIsTooHigh):
+		return "bid_too_high"
+	case errors.Is(err, ErrInvalidQuantity):
+		return "invalid_quantity"
+	case errors.Is(err, ErrSelfBid):
+		return "self_bid"
+	case errors.Is(err, ErrAuctionClosed):
+		return "auction_closed"
+	case errors.Is(err, ErrAuctionNotOpen):
+		return "auction_not_open"
+	case errors.Is(err, ErrWrongAuctionType):
+		return "wrong_auction_type"
+	case errors.Is(err, ErrSealedBidPlaced):
+		return "sealed_bid_placed"
+	case errors.Is(err, ErrBuyNowUnavailable):
+		return "buy_now_unavailable"
+	case errors.Is(err, ErrNotProductSeller):
+		return "not_product_seller"
+	case errors.Is(err, ErrCancelNotAllowed):
+		return "cancel_not_allowed"
+	case errors.Is(err, ErrNoBidToAccept):
+		return "no_bid_to_accept"
+	case errors.Is(err, ErrNoBidToRetract):
+		return "no_bid_to_retract"
+	case errors.Is(err, ErrRetractionWindowPassed):
+		return "retraction_window_passed"
+	case errors.Is(err, ErrRetractionTooLate):
+		return "retraction_too_late"
+	case errors.Is(err, ErrRetractionLimitExceeded):
+		return "retraction_limit_exceeded"
+	case errors.Is(err, ErrUnknownMessageType):
+		return "unknown_type"
+	case errors.Is(err, ErrRoomIsBusy):
+		return "room_busy"
+	}
+	return "internal_error"
+}
+
+// Envelope is a Message in ProtocolV2. Type names what it is, and answers
+// carry the ID of the request they answer so clients can match them.
+type Envelope struct {
+	Version int    `json:"v"`
+	Type    string `json:"type"`
+	// ID is chosen by the client for its requests and echoed on the answers.
+	ID        string    `json:"id,omitempty"`
+	Seq       uint64    `json:"seq,omitempty"`
+	ProductID uuid.UUID `json:"product_id,omitempty"`
+
+	Message       string        `json:"message,omitempty"`
+	Amount        money.Amount  `json:"amount,omitempty"`
+	UserID        uuid.UUID     `json:"user_id,omitempty"`
+	Bidder        string        `json:"bidder,omitempty"`
+	IsYou         bool          `json:"is_you,omitempty"`
+	ReserveMet    *bool         `json:"reserve_met,omitempty"`
+	EndsAt        *time.Time    `json:"ends_at,omitempty"`
+	Bids          []RevealedBid `json:"bids,omitempty"`
+	Quantity      int32         `json:"quantity,omitempty"`
+	ClearingPrice *money.Amount `json:"clearing_price,omitempty"`
+	Snapshot      *Snapshot     `json:"snapshot,omitempty"`
+
+	Error *ProtocolError `json:"error,omitempty"`
+}
+
+// ProtocolError tells why a request failed, see ErrorCode.
+type ProtocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func envelope(m Message) Envelope {
+	e := Envelope{
+		Version:       2,
+		Type:          messageTypes[m.Kind],
+		ID:            m.requestID,
+		Seq:           m.Seq,
+		ProductID:     m.ProductID,
+		Message:       m.Message,
+		Amount:        m.Amount,
+		UserID:        m.UserID,
+		Bidder:        m.Bidder,
+		IsYou:         m.IsYou,
+		ReserveMet:    m.ReserveMet,
+		EndsAt:        m.EndsAt,
+		Bids:          m.Bids,
+		Quantity:      m.Quantity,
+		ClearingPrice: m.ClearingPrice,
+		Snapshot:      m.Snapshot,
+	}
+
+	if m.Kind.isError() {
+		// Errors raised by the connection itself are their own code.
+		code := e.Type
+		if m.err != nil {
+			code = ErrorCode(m.err)
+		}
+		e.Error = &ProtocolError{Code: code, Message: m.Message}
+	}
+
+	return e
+}
+
+// decodeMessage reads a client request in protocol.
+func decodeMessage(protocol string, r io.Reader) Message {
+	invalid := Message{Kind: InvalidJSON, Message: "this message should be a valid JSON"}
+
+	if protocol != ProtocolV2 {
+		var m Message
+		if err := json.NewDecoder(r).Decode(&m); err != nil {
+			return invalid
+		}
+		return m
+	}
+
+	var e Envelope
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return invalid
+	}
+
+	kind, ok := requestKinds[e.Type]
+	if !ok {
+		return Message{Kind: InvalidJSON, Message: "unknown request type " + e.Type, requestID: e.ID, err: ErrUnknownMessageType}
+	}
+
+	return Message{
+		Kind:      kind,
+		Amount:    e.Amount,
+		Quantity:  e.Quantity,
+		ProductID: e.ProductID,
+		requestID: e.ID,
+	}
+}
+
+// encodeMessage is what m looks like in protocol.
+func encodeMessage(protocol string, m Message) any {
+	if protocol == ProtocolV2 {
+		return envelope(m)
+	}
+	return m
+}
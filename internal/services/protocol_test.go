@@ -0,0 +1,200 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// v1Kinds pins the integers existing clients know every kind by. A kind
+// must never change its value, new ones are only appended.
+var v1Kinds = map[MessageKind]int{
+	PlaceBid:                     0,
+	SuccessfullyPlacedBid:        1,
+	FailedToPlaceBid:             2,
+	InvalidJSON:                  3,
+	NewBidPlaced:                 4,
+	AuctionFinished:              5,
+	AuctionWon:                   6,
+	AuctionSold:                  7,
+	AuctionUnsold:                8,
+	PlaceMaxBid:                  9,
+	SuccessfullyPlacedMaxBid:     10,
+	BuyNow:                       11,
+	SuccessfullyBoughtNow:        12,
+	FailedToBuyNow:               13,
+	AuctionBoughtNow:             14,
+	AuctionExtended:              15,
+	AcceptPrice:                  16,
+	SuccessfullyAcceptedPrice:    17,
+	FailedToAcceptPrice:          18,
+	PriceDropped:                 19,
+	BidsRevealed:                 20,
+	CancelAuction:                21,
+	CloseAuctionEarly:            22,
+	SuccessfullyCancelledAuction: 23,
+	SuccessfullyClosedAuction:    24,
+	FailedToCloseAuction:         25,
+	AuctionCancelled:             26,
+	FailedToReachLot:             27,
+	CurrentLotChanged:            28,
+	EventFinished:                29,
+	RetractBid:                   30,
+	SuccessfullyRetractedBid:     31,
+	FailedToRetractBid:           32,
+	BidRetracted:                 33,
+	AuctionSnapshot:              34,
+	TooManyConnections:           35,
+}
+
+func TestV1KindsKeepTheirValues(t *testing.T) {
+	if len(v1Kinds) != int(messageKinds) {
+		t.Fatalf("%d kinds pinned, %d declared: pin the new ones", len(v1Kinds), messageKinds)
+	}
+
+	for kind, want := range v1Kinds {
+		raw, err := json.Marshal(encodeMessage(ProtocolV1, Message{Kind: kind}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var got struct {
+			Kind *int `json:"kind"`
+		}
+		if err := json.Unmarshal(raw, &got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Kind == nil || *got.Kind != want {
+			t.Errorf("%s is encoded as %s in v1, want kind %d", messageTypes[kind], raw, want)
+		}
+	}
+}
+
+func TestEveryKindHasAV2Type(t *testing.T) {
+	seen := make(map[string]MessageKind)
+	for kind := MessageKind(0); kind < messageKinds; kind++ {
+		name := messageTypes[kind]
+		if name == "" {
+			t.Errorf("kind %d has no v2 type", kind)
+			continue
+		}
+		if other, ok := seen[name]; ok {
+			t.Errorf("kinds %d and %d are both %q", other, kind, name)
+		}
+		seen[name] = kind
+	}
+
+	if len(messageTypes) != int(messageKinds) {
+		t.Errorf("%d v2 types for %d kinds", len(messageTypes), messageKinds)
+	}
+}
+
+func TestV2RequestsDecode(t *testing.T) {
+	for name, kind := range requestKinds {
+		m := decodeMessage(ProtocolV2, strings.NewReader(`{"v":2,"type":"`+name+`","id":"req-1","amount":"12.50","quantity":2}`))
+		if m.Kind != kind || m.requestID != "req-1" || m.Amount != 1250 || m.Quantity != 2 {
+			t.Errorf("%s decoded as %+v", name, m)
+		}
+	}
+}
+
+// v2Answer is the envelope a v2 client receives in answer to a request
+// with id req-7, after the room handled it.
+func v2Answer(t *testing.T, request Message, answer Message) Envelope {
+	t.Helper()
+
+	room := &AuctionRoom{}
+	request.reply = make(chan Message, 1)
+	room.reply(request, answer)
+
+	raw, err := json.Marshal(encodeMessage(ProtocolV2, <-request.reply))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var e Envelope
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestV2AnswersCarryRequestIDAndErrorCode(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   Message
+		wantType string
+		wantCode string
+	}{
+		{
+			name:     "success",
+			answer:   Message{Kind: SuccessfullyPlacedBid, Message: "Your bid was successfully placed"},
+			wantType: "bid_placed",
+		},
+		{
+			name:     "bid too low",
+			answer:   Message{Kind: FailedToPlaceBid, err: &BidTooLowError{Minimum: 1300}},
+			wantType: "bid_failed",
+			wantCode: "bid_too_low",
+		},
+		{
+			name:     "self bid",
+			answer:   Message{Kind: FailedToPlaceBid, err: ErrSelfBid},
+			wantType: "bid_failed",
+			wantCode: "self_bid",
+		},
+		{
+			name:     "retraction too late",
+			answer:   Message{Kind: FailedToRetractBid, err: ErrRetractionTooLate},
+			wantType: "retract_bid_failed",
+			wantCode: "retraction_too_late",
+		},
+		{
+			name:     "unexpected error",
+			answer:   Message{Kind: FailedToBuyNow, err: errors.New("connection reset")},
+			wantType: "buy_now_failed",
+			wantCode: "internal_error",
+		},
+		{
+			name:     "connection error",
+			answer:   Message{Kind: FailedToReachLot},
+			wantType: "lot_unreachable",
+			wantCode: "lot_unreachable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := decodeMessage(ProtocolV2, strings.NewReader(`{"v":2,"type":"place_bid","id":"req-7","amount":"12.50"}`))
+			e := v2Answer(t, request, tt.answer)
+
+			if e.Version != 2 || e.Type != tt.wantType || e.ID != "req-7" {
+				t.Errorf("got v%d %q with id %q, want v2 %q with id req-7", e.Version, e.Type, e.ID, tt.wantType)
+			}
+			switch {
+			case tt.wantCode == "" && e.Error != nil:
+				t.Errorf("got error %+v, want none", e.Error)
+			case tt.wantCode != "" && (e.Error == nil || e.Error.Code != tt.wantCode):
+				t.Errorf("got error %+v, want code %s", e.Error, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestV2UnknownRequestType(t *testing.T) {
+	m := decodeMessage(ProtocolV2, strings.NewReader(`{"v":2,"type":"new_bid","id":"req-9"}`))
+
+	raw, err := json.Marshal(encodeMessage(ProtocolV2, m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e Envelope
+	if err := json.Unmarshal(raw, &e); err != nil {
+		t.Fatal(err)
+	}
+
+	if e.Type != "invalid_message" || e.ID != "req-9" || e.Error == nil || e.Error.Code != "unknown_type" {
+		t.Errorf("got %s, want an unknown_type error for req-9", raw)
+	}
+}
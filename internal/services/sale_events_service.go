@@ -0,0 +1,417 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gobid/internal/store/pgstore"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SaleEventsService struct {
+	pool    *pgxpool.Pool
+	queries *pgstore.Queries
+}
+
+func NewSaleEventsService(pool *pgxpool.Pool) SaleEventsService {
+	return SaleEventsService{
+		pool:    pool,
+		queries: pgstore.New(pool),
+	}
+}
+
+var ErrSaleEventNotFound = errors.New("sale event not found")
+
+// NewSaleEvent is a catalogue of lots sold one after another. Every lot
+// opens at AuctionStart, the first one closes at FirstLotEnd and each of the
+// next ones LotInterval after the previous one.
+type NewSaleEvent struct {
+	Name         string
+	AuctionStart time.Time
+	FirstLotEnd  time.Time
+	LotInterval  time.Duration
+	Lots         []NewProduct
+}
+
+// CreateSaleEvent lists every lot of e on behalf of sellerId, numbered from 1
+// in the order they were given.
+func (ss *SaleEventsService) CreateSaleEvent(ctx context.Context, sellerId uuid.UUID, e NewSaleEvent) (pgstore.SaleEvent, []pgstore.Product, error) {
+	tx, err := ss.pool.Begin(ctx)
+	if err != nil {
+		return pgstore.SaleEvent{}, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	queries := ss.queries.WithTx(tx)
+
+	event, err := queries.CreateSaleEvent(ctx, pgstore.CreateSaleEventParams{
+		SellerID:           sellerId,
+		Name:               e.Name,
+		FirstLotEnd:        e.FirstLotEnd,
+		LotIntervalSeconds: int32(e.LotInterval / time.Second),
+	})
+	if err != nil {
+		return pgstore.SaleEvent{}, nil, err
+	}
+
+	lots := make([]pgstore.Product, 0, len(e.Lots))
+	for i, lot := range e.Lots {
+		lot.AuctionStart = e.AuctionStart
+		lot.AuctionEnd = e.FirstLotEnd.Add(time.Duration(i) * e.LotInterval)
+		lot.AuctionType = pgstore.AuctionTypeEnglish
+
+		product, err := createProduct(ctx, queries, sellerId, lot)
+		if err != nil {
+			return pgstore.SaleEvent{}, nil, err
+		}
+
+		product, err = queries.SetProductLot(ctx, pgstore.SetProductLotParams{
+			ID:          product.ID,
+			SaleEventID: uuid.NullUUID{UUID: event.ID, Valid: true},
+			LotNumber:   pgtype.Int4{Int32: int32(i + 1), Valid: true},
+		})
+		if err != nil {
+			return pgstore.SaleEvent{}, nil, err
+		}
+
+		lots = append(lots, product)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return pgstore.SaleEvent{}, nil, err
+	}
+
+	return event, lots, nil
+}
+
+// GetSaleEvent returns the event with id and its lots ordered by lot number.
+func (ss *SaleEventsService) GetSaleEvent(ctx context.Context, id uuid.UUID) (pgstore.SaleEvent, []pgstore.Product, error) {
+	event, err := ss.queries.GetSaleEventById(ctx, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return pgstore.SaleEvent{}, nil, ErrSaleEventNotFound
+		}
+		return pgstore.SaleEvent{}, nil, err
+	}
+
+	lots, err := ss.queries.ListProductsBySaleEventId(ctx, uuid.NullUUID{UUID: id, Valid: true})
+	if err != nil {
+		return pgstore.SaleEvent{}, nil, err
+	}
+
+	return event, lots, nil
+}
+
+// EventRoom returns the room following the lots of event, starting it when
+// nobody follows the event yet with the connection limit of bs. It reports
+// false once every lot is over.
+func (l *AuctionLobby) EventRoom(event pgstore.SaleEvent, lots []pgstore.Product, bs BidsService) (*SaleEventRoom, bool) {
+	l.Lock()
+	defer l.Unlock()
+
+	if room, ok := l.events[event.ID]; ok {
+		return room, true
+	}
+
+	room := newSaleEventRoom(event, lots, l, bs.config.MaxConnectionsPerUser)
+	for _, lot := range lots {
+		if lotRoom, ok := l.Rooms[lot.ID]; ok {
+			go room.follow(lotRoom)
+			continue
+		}
+		// A lot that is neither running nor waiting to open is over, even
+		// if it was still open when lots were read.
+		if _, ok := l.scheduled[lot.ID]; !ok {
+			room.closed[lot.ID] = true
+		}
+	}
+
+	if room.finished() {
+		return nil, false
+	}
+
+	if l.events == nil {
+		l.events = make(map[uuid.UUID]*SaleEventRoom)
+	}
+	l.events[event.ID] = room
+
+	go func() {
+		room.Run()
+
+		l.Lock()
+		if l.events[room.Id] == room {
+			delete(l.events, room.Id)
+		}
+		l.Unlock()
+	}()
+
+	return room, true
+}
+
+// SaleEventRoom follows every lot of a sale event. Its clients receive the
+// announcements of all the lots, tagged with their ProductID, and are told
+// whenever the next lot starts closing. Requests are forwarded to the lot
+// they name, or to the one currently closing.
+type SaleEventRoom struct {
+	Id uuid.UUID
+	// Context is cancelled once every lot is over.
+	Context    context.Context
+	Broadcast  chan Message
+	Register   chan *Client
+	Unregister chan *Client
+	Clients    map[*Client]bool
+
+	// Lots are the products of the event ordered by lot number.
+	Lots []pgstore.Product
+
+	cancel context.CancelFunc
+	// updates receives the announcements of the lot rooms and their answers
+	// to the requests forwarded to them.
+	updates chan Message
+	closed  map[uuid.UUID]bool
+	ends    map[uuid.UUID]time.Time
+	// current is the index in Lots of the lot closing next.
+	current int
+	lobby   *AuctionLobby
+	// maxConnections caps the connections of each user to the room.
+	maxConnections int
+}
+
+// submitTimeout bounds how long a request forwarded to a lot room waits for
+// its answer.
+const submitTimeout = 10 * time.Second
+
+func newSaleEventRoom(event pgstore.SaleEvent, lots []pgstore.Product, lobby *AuctionLobby, maxConnections int) *SaleEventRoom {
+	ctx, cancel := context.WithCancel(context.Background())
+	room := &SaleEventRoom{
+		Id:         event.ID,
+		Context:    ctx,
+		Broadcast:  make(chan Message),
+		Register:   make(chan *Client),
+		Unregister: make(chan *Client),
+		Clients:    make(map[*Client]bool),
+		Lots:       lots,
+		cancel:     cancel,
+		updates:    make(chan Message, 64),
+		closed:     make(map[uuid.UUID]bool),
+		ends:       make(map[uuid.UUID]time.Time),
+		current:    -1,
+		lobby:      lobby,
+
+		maxConnections: maxConnections,
+	}
+
+	for _, lot := range lots {
+		room.ends[lot.ID] = lot.AuctionEnd
+		if lot.ClosedAt.Valid {
+			room.closed[lot.ID] = true
+		}
+	}
+
+	return room
+}
+
+func (r *SaleEventRoom) channels() (chan<- Message, chan<- *Client, <-chan struct{}) {
+	return r.Broadcast, r.Unregister, r.Context.Done()
+}
+
+// followBuffer is how many announcements of a lot may wait for the event
+// room before the lot drops it.
+const followBuffer = 64
+
+// follow relays the announcements of lot to the event room through a queue
+// of its own, so a busy event room never holds up the lot. A lot that is
+// already over, or that dropped the event room for falling behind, is
+// counted as closed once it ends.
+func (r *SaleEventRoom) follow(lot *AuctionRoom) {
+	updates := make(chan Message, followBuffer)
+	if lot.Watch(updates, r.Context.Done()) {
+		for m := range updates {
+			select {
+			case r.updates <- m:
+			case <-r.Context.Done():
+				return
+			}
+			if m.to == uuid.Nil && m.Kind.closesAuction() {
+				return
+			}
+		}
+
+		select {
+		case <-lot.Context.Done():
+		case <-r.Context.Done():
+			return
+		}
+	}
+
+	select {
+	case r.updates <- Message{Kind: AuctionFinished, Message: "Auction has been finished", ProductID: lot.Id}:
+	case <-r.Context.Done():
+	}
+}
+
+func (r *SaleEventRoom) finished() bool {
+	return len(r.closed) == len(r.Lots)
+}
+
+// currentLot moves to the first lot that is not over yet and reports whether
+// it changed.
+func (r *SaleEventRoom) currentLot() bool {
+	for i, lot := range r.Lots {
+		if !r.closed[lot.ID] {
+			changed := i != r.current
+			r.current = i
+			return changed
+		}
+	}
+	return false
+}
+
+func (r *SaleEventRoom) currentLotMessage() Message {
+	lot := r.Lots[r.current]
+	end := r.ends[lot.ID]
+	return Message{
+		Kind:      CurrentLotChanged,
+		Message:   "Lot " + lot.ProductName + " is closing next",
+		ProductID: lot.ID,
+		EndsAt:    &end,
+	}
+}
+
+func (r *SaleEventRoom) registerClient(c *Client) {
+	if !admit(r.Clients, c, r.maxConnections) {
+		return
+	}
+	slog.Info("New user connected to sale event", "Client", c)
+	r.push(c, r.currentLotMessage())
+}
+
+func (r *SaleEventRoom) unregisterClient(c *Client) {
+	slog.Info("User disconnected from sale event", "Client", c)
+	delete(r.Clients, c)
+}
+
+// relay hands a lot message to the clients it is meant for and keeps track
+// of which lot is closing next.
+func (r *SaleEventRoom) relay(m Message) {
+	if m.to != uuid.Nil {
+		for client := range r.Clients {
+			if client.UserID == m.to {
+				r.push(client, m)
+			}
+		}
+		return
+	}
+
+	if m.Kind == AuctionExtended && m.EndsAt != nil {
+		r.ends[m.ProductID] = *m.EndsAt
+	}
+
+	for client := range r.Clients {
+		r.push(client, m)
+	}
+
+	if !m.Kind.closesAuction() {
+		return
+	}
+
+	r.closed[m.ProductID] = true
+	if !r.finished() && r.currentLot() {
+		r.announce(r.currentLotMessage())
+	}
+}
+
+// push sends m to c without blocking the room, dropping c when it can not
+// keep up, see Client.push.
+func (r *SaleEventRoom) push(c *Client, m Message) {
+	if !c.push(m, r.resync) {
+		delete(r.Clients, c)
+	}
+}
+
+// resync tells a client that skipped messages which lot is closing next
+// before handing it m. The lot rooms answer for the rest of their state.
+func (r *SaleEventRoom) resync(c *Client, m Message) []Message {
+	return []Message{r.currentLotMessage(), m}
+}
+
+func (r *SaleEventRoom) announce(m Message) {
+	for client := range r.Clients {
+		r.push(client, m)
+	}
+}
+
+// forward submits a client request to the lot it names and relays the lot
+// answer back to the client.
+func (r *SaleEventRoom) forward(m Message) {
+	// A connection turned away by admit may still have sent something.
+	if !r.Clients[m.from] {
+		return
+	}
+
+	if m.Kind == InvalidJSON {
+		r.push(m.from, m)
+		return
+	}
+
+	if m.ProductID == uuid.Nil {
+		m.ProductID = r.Lots[r.current].ID
+	}
+
+	r.lobby.Lock()
+	lot, ok := r.lobby.Rooms[m.ProductID]
+	r.lobby.Unlock()
+
+	if !ok {
+		r.push(m.from, Message{Kind: FailedToReachLot, Message: "this lot is not open", ProductID: m.ProductID, requestID: m.requestID})
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(r.Context, submitTimeout)
+		defer cancel()
+
+		answer, err := lot.Submit(ctx, m)
+		if err != nil {
+			answer = Message{Kind: FailedToReachLot, Message: err.Error(), err: err}
+		}
+		answer.ProductID = m.ProductID
+		answer.requestID = m.requestID
+		answer.to = m.UserID
+
+		select {
+		case r.updates <- answer:
+		case <-r.Context.Done():
+		}
+	}()
+}
+
+func (r *SaleEventRoom) Run() {
+	slog.Info("Sale event room has started", "eventId", r.Id)
+	defer r.cancel()
+
+	r.currentLot()
+
+	for {
+		select {
+		case client := <-r.Register:
+			r.registerClient(client)
+		case client := <-r.Unregister:
+			r.unregisterClient(client)
+		case message := <-r.Broadcast:
+			r.forward(message)
+		case message := <-r.updates:
+			r.relay(message)
+			if r.finished() {
+				slog.Info("Sale event has ended", "eventId", r.Id)
+				r.announce(Message{Kind: EventFinished, Message: "Every lot of the sale event is over"})
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"gobid/internal/money"
+	"gobid/internal/store/pgstore"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// forwardBid sends a bid of amount by bidder to lot through the room of a
+// sale event and returns the answer the bidder gets.
+func forwardBid(t *testing.T, lot *AuctionRoom, bidder uuid.UUID, amount money.Amount) Message {
+	t.Helper()
+
+	lobby := &AuctionLobby{Rooms: map[uuid.UUID]*AuctionRoom{lot.Id: lot}}
+	event := newSaleEventRoom(pgstore.SaleEvent{ID: uuid.New()}, []pgstore.Product{lot.Product}, lobby, 0)
+	defer event.cancel()
+	event.currentLot()
+
+	client := newTestClient(DisconnectSlowClients, 4)
+	client.UserID = bidder
+	event.Clients[client] = true
+
+	// The lot handles the one request it gets, without running until its
+	// auction ends.
+	go func() {
+		select {
+		case m := <-lot.Broadcast:
+			lot.brodcastMessage(m)
+		case <-lot.Context.Done():
+		}
+	}()
+
+	event.forward(Message{Kind: PlaceBid, Amount: amount, UserID: bidder, from: client, requestID: "req-1"})
+
+	select {
+	case answer := <-event.updates:
+		event.relay(answer)
+	case <-time.After(submitTimeout + time.Second):
+		t.Fatal("the lot never answered")
+	}
+
+	answer := <-client.Send
+	if answer.requestID != "req-1" || answer.ProductID != lot.Id {
+		t.Errorf("answer is for request %q on %s, want req-1 on %s", answer.requestID, answer.ProductID, lot.Id)
+	}
+	return answer
+}
+
+func TestSaleEventForwardsBidToLot(t *testing.T) {
+	// The lot can only answer that it failed without a database.
+	product := pgstore.Product{ID: uuid.New(), AuctionType: pgstore.AuctionTypeEnglish, AuctionEnd: time.Now().Add(time.Hour)}
+	lot := NewAuctionRoom(product, NewBidsService(unreachablePool(t), DefaultAuctionConfig()))
+	defer lot.cancel()
+
+	answer := forwardBid(t, lot, uuid.New(), 10_00)
+	if answer.Kind != FailedToPlaceBid || ErrorCode(answer.err) != "internal_error" {
+		t.Errorf("got %v, want the lot to fail placing the bid", answer)
+	}
+}
+
+func TestSaleEventPlacesBidOnLot(t *testing.T) {
+	pool := testPool(t)
+	ctx := context.Background()
+
+	users := NewUserService(pool)
+	products := NewProductsService(pool)
+	bids := NewBidsService(pool, DefaultAuctionConfig())
+
+	seller := createTestUser(t, users)
+	bidder := createTestUser(t, users)
+
+	product, err := products.CreateProduct(ctx, seller, NewProduct{
+		ProductName:   "Forwarded bid",
+		Baseprice:     100_00,
+		Currency:      money.USD,
+		AuctionEnd:    time.Now().Add(time.Hour),
+		AuctionType:   pgstore.AuctionTypeEnglish,
+		BidIncrements: IncrementTable{{Increment: 1_00}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lot := NewAuctionRoom(product, bids)
+	defer lot.cancel()
+
+	answer := forwardBid(t, lot, bidder, 110_00)
+	if answer.Kind != SuccessfullyPlacedBid || answer.Amount != 110_00 {
+		t.Errorf("got %v, want the bid of 110.00 placed", answer)
+	}
+}
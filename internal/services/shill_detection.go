@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"gobid/internal/store/pgstore"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// A bidder with at least singleSellerMinBids bids, singleSellerShare
+	// percent of them on one seller's products, only seems to bid for that
+	// seller.
+	singleSellerMinBids = 10
+	singleSellerShare   = 90
+	// Bids retracted on one seller's products before a bidder is flagged.
+	retractionsToFlag = 2
+	// An account younger than newAccountAge that bid newAccountMinBids times
+	// on an auction it did not win only pushed its price.
+	newAccountAge     = 7 * 24 * time.Hour
+	newAccountMinBids = 2
+)
+
+// FlagShillBidding looks at everyone who bid on the auction of result once it
+// is over and records a flag for moderators on every suspicious pattern:
+// bidders who only bid on this seller's products, who keep retracting their
+// bids on them, or new accounts that pushed the price without winning.
+func (bs *BidsService) FlagShillBidding(ctx context.Context, result AuctionResult) error {
+	product := result.Product
+
+	bidders, err := bs.queries.ListBidderActivityByProductId(ctx, pgstore.ListBidderActivityByProductIdParams{
+		ProductID: product.ID,
+		SellerID:  product.SellerID,
+	})
+	if err != nil {
+		return err
+	}
+
+	winners := make(map[uuid.UUID]bool)
+	if result.Sold {
+		winners[result.LeadingBid.BidderID] = true
+	}
+	for _, sale := range result.Sales {
+		winners[sale.BuyerID] = true
+	}
+
+	closedAt := time.Now()
+	for _, bidder := range bidders {
+		var flags []pgstore.CreateShillFlagParams
+		flag := func(reason pgstore.ShillFlagReason, details string) {
+			flags = append(flags, pgstore.CreateShillFlagParams{
+				ProductID: product.ID,
+				BidderID:  bidder.BidderID,
+				SellerID:  product.SellerID,
+				Reason:    reason,
+				Details:   details,
+			})
+		}
+
+		if bidder.TotalBids >= singleSellerMinBids && bidder.SellerBids*100 >= bidder.TotalBids*singleSellerShare {
+			flag(pgstore.ShillFlagReasonSingleSellerBidder,
+				fmt.Sprintf("%d of %d bids placed on this seller's products", bidder.SellerBids, bidder.TotalBids))
+		}
+
+		if bidder.SellerRetractions >= retractionsToFlag {
+			flag(pgstore.ShillFlagReasonFrequentRetractions,
+				fmt.Sprintf("%d bids retracted on this seller's products", bidder.SellerRetractions))
+		}
+
+		if age := closedAt.Sub(bidder.AccountCreatedAt); age < newAccountAge && bidder.ProductBids >= newAccountMinBids && !winners[bidder.BidderID] {
+			flag(pgstore.ShillFlagReasonNewAccountPricePushing,
+				fmt.Sprintf("%d bids without winning from an account created %s before the auction ended", bidder.ProductBids, age.Round(time.Minute)))
+		}
+
+		for _, arg := range flags {
+			if err := bs.queries.CreateShillFlag(ctx, arg); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// flagShillBidding records suspicious bidding on the auction once everyone
+// has heard how it ended.
+func (r *AuctionRoom) flagShillBidding(result AuctionResult) {
+	ctx, cancel := context.WithTimeout(context.Background(), settlementTimeout)
+	defer cancel()
+
+	if err := r.BidsService.FlagShillBidding(ctx, result); err != nil {
+		slog.Error("Failed to flag shill bidding", "auctionId", r.Id, "error", err)
+	}
+}
@@ -7,20 +7,52 @@ package pgstore
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gobid/internal/money"
 )
 
+const countBidsByProductId = `-- name: CountBidsByProductId :one
+SELECT count(*) FROM bids
+WHERE product_id = $1 AND voided_at IS NULL
+`
+
+func (q *Queries) CountBidsByProductId(ctx context.Context, productID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, countBidsByProductId, productID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRetractionsByBidderSince = `-- name: CountRetractionsByBidderSince :one
+SELECT count(*) FROM bids
+WHERE bidder_id = $1 AND retracted_at >= $2
+`
+
+type CountRetractionsByBidderSinceParams struct {
+	BidderID    uuid.UUID          `json:"bidder_id"`
+	RetractedAt pgtype.Timestamptz `json:"retracted_at"`
+}
+
+func (q *Queries) CountRetractionsByBidderSince(ctx context.Context, arg CountRetractionsByBidderSinceParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRetractionsByBidderSince, arg.BidderID, arg.RetractedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createBid = `-- name: CreateBid :one
 INSERT INTO bids (
   product_id, bidder_id, bid_amount
-) VALUES ($1, $2, $3) RETURNING id, product_id, bidder_id, bid_amount, created_at
+) VALUES ($1, $2, $3) RETURNING id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at
 `
 
 type CreateBidParams struct {
-	ProductID uuid.UUID `json:"product_id"`
-	BidderID  uuid.UUID `json:"bidder_id"`
-	BidAmount float64   `json:"bid_amount"`
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	BidAmount money.Amount `json:"bid_amount"`
 }
 
 func (q *Queries) CreateBid(ctx context.Context, arg CreateBidParams) (Bid, error) {
@@ -32,14 +64,77 @@ func (q *Queries) CreateBid(ctx context.Context, arg CreateBidParams) (Bid, erro
 		&i.BidderID,
 		&i.BidAmount,
 		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const createBidForQuantity = `-- name: CreateBidForQuantity :one
+INSERT INTO bids (
+  product_id, bidder_id, bid_amount, quantity
+) VALUES ($1, $2, $3, $4) RETURNING id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at
+`
+
+type CreateBidForQuantityParams struct {
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	BidAmount money.Amount `json:"bid_amount"`
+	Quantity  int32        `json:"quantity"`
+}
+
+func (q *Queries) CreateBidForQuantity(ctx context.Context, arg CreateBidForQuantityParams) (Bid, error) {
+	row := q.db.QueryRow(ctx, createBidForQuantity,
+		arg.ProductID,
+		arg.BidderID,
+		arg.BidAmount,
+		arg.Quantity,
+	)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const getBidByBidder = `-- name: GetBidByBidder :one
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
+WHERE product_id = $1 AND bidder_id = $2
+`
+
+type GetBidByBidderParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	BidderID  uuid.UUID `json:"bidder_id"`
+}
+
+func (q *Queries) GetBidByBidder(ctx context.Context, arg GetBidByBidderParams) (Bid, error) {
+	row := q.db.QueryRow(ctx, getBidByBidder, arg.ProductID, arg.BidderID)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
 	)
 	return i, err
 }
 
 const getBidsByProductId = `-- name: GetBidsByProductId :many
-SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
 WHERE product_id = $1
-ORDER BY bid_amount DESC
+ORDER BY bid_amount DESC, created_at ASC
 `
 
 func (q *Queries) GetBidsByProductId(ctx context.Context, productID uuid.UUID) ([]Bid, error) {
@@ -57,6 +152,9 @@ func (q *Queries) GetBidsByProductId(ctx context.Context, productID uuid.UUID) (
 			&i.BidderID,
 			&i.BidAmount,
 			&i.CreatedAt,
+			&i.VoidedAt,
+			&i.Quantity,
+			&i.RetractedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -69,8 +167,8 @@ func (q *Queries) GetBidsByProductId(ctx context.Context, productID uuid.UUID) (
 }
 
 const getHighestBidByProductId = `-- name: GetHighestBidByProductId :one
-SELECT id, product_id, bidder_id, bid_amount, created_at FROM bids
-WHERE product_id = $1
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
+WHERE product_id = $1 AND voided_at IS NULL
 ORDER BY bid_amount DESC
 LIMIT 1
 `
@@ -84,6 +182,180 @@ func (q *Queries) GetHighestBidByProductId(ctx context.Context, productID uuid.U
 		&i.BidderID,
 		&i.BidAmount,
 		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const getLatestBidByBidder = `-- name: GetLatestBidByBidder :one
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
+WHERE product_id = $1 AND bidder_id = $2 AND voided_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+type GetLatestBidByBidderParams struct {
+	ProductID uuid.UUID `json:"product_id"`
+	BidderID  uuid.UUID `json:"bidder_id"`
+}
+
+func (q *Queries) GetLatestBidByBidder(ctx context.Context, arg GetLatestBidByBidderParams) (Bid, error) {
+	row := q.db.QueryRow(ctx, getLatestBidByBidder, arg.ProductID, arg.BidderID)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
 	)
 	return i, err
 }
+
+const getLowestBidByProductId = `-- name: GetLowestBidByProductId :one
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
+WHERE product_id = $1 AND voided_at IS NULL
+ORDER BY bid_amount ASC
+LIMIT 1
+`
+
+func (q *Queries) GetLowestBidByProductId(ctx context.Context, productID uuid.UUID) (Bid, error) {
+	row := q.db.QueryRow(ctx, getLowestBidByProductId, productID)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const listBidsByProductIdBefore = `-- name: ListBidsByProductIdBefore :many
+SELECT id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at FROM bids
+WHERE product_id = $1 AND voided_at IS NULL
+  AND (created_at, id) < ($2::timestamptz, $3::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
+`
+
+type ListBidsByProductIdBeforeParams struct {
+	ProductID       uuid.UUID `json:"product_id"`
+	BeforeCreatedAt time.Time `json:"before_created_at"`
+	BeforeID        uuid.UUID `json:"before_id"`
+	PageSize        int32     `json:"page_size"`
+}
+
+func (q *Queries) ListBidsByProductIdBefore(ctx context.Context, arg ListBidsByProductIdBeforeParams) ([]Bid, error) {
+	rows, err := q.db.Query(ctx, listBidsByProductIdBefore,
+		arg.ProductID,
+		arg.BeforeCreatedAt,
+		arg.BeforeID,
+		arg.PageSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Bid
+	for rows.Next() {
+		var i Bid
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProductID,
+			&i.BidderID,
+			&i.BidAmount,
+			&i.CreatedAt,
+			&i.VoidedAt,
+			&i.Quantity,
+			&i.RetractedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockBidderRetractions = `-- name: LockBidderRetractions :exec
+SELECT pg_advisory_xact_lock(hashtextextended('retractions:' || $1::uuid::text, 0))
+`
+
+func (q *Queries) LockBidderRetractions(ctx context.Context, bidderID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, lockBidderRetractions, bidderID)
+	return err
+}
+
+const retractBid = `-- name: RetractBid :one
+UPDATE bids
+SET voided_at = now(), retracted_at = now()
+WHERE id = $1
+RETURNING id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at
+`
+
+func (q *Queries) RetractBid(ctx context.Context, id uuid.UUID) (Bid, error) {
+	row := q.db.QueryRow(ctx, retractBid, id)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const updateBidAmount = `-- name: UpdateBidAmount :one
+UPDATE bids
+SET bid_amount = $2, created_at = now()
+WHERE id = $1
+RETURNING id, product_id, bidder_id, bid_amount, created_at, voided_at, quantity, retracted_at
+`
+
+type UpdateBidAmountParams struct {
+	ID        uuid.UUID    `json:"id"`
+	BidAmount money.Amount `json:"bid_amount"`
+}
+
+func (q *Queries) UpdateBidAmount(ctx context.Context, arg UpdateBidAmountParams) (Bid, error) {
+	row := q.db.QueryRow(ctx, updateBidAmount, arg.ID, arg.BidAmount)
+	var i Bid
+	err := row.Scan(
+		&i.ID,
+		&i.ProductID,
+		&i.BidderID,
+		&i.BidAmount,
+		&i.CreatedAt,
+		&i.VoidedAt,
+		&i.Quantity,
+		&i.RetractedAt,
+	)
+	return i, err
+}
+
+const voidBidsByProductId = `-- name: VoidBidsByProductId :exec
+UPDATE bids
+SET voided_at = now()
+WHERE product_id = $1 AND voided_at IS NULL
+`
+
+func (q *Queries) VoidBidsByProductId(ctx context.Context, productID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, voidBidsByProductId, productID)
+	return err
+}
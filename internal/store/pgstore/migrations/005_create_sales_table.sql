@@ -0,0 +1,15 @@
+-- Write your migrate up statements here
+CREATE TABLE IF NOT EXISTS sales (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id UUID UNIQUE NOT NULL REFERENCES products (id),
+    bid_id UUID NOT NULL REFERENCES bids (id),
+    buyer_id UUID NOT NULL REFERENCES users (id),
+    seller_id UUID NOT NULL REFERENCES users (id),
+    final_price FLOAT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now ()
+)
+---- create above / drop below ----
+DROP TABLE IF EXISTS sales;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
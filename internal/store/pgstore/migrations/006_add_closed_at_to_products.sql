@@ -0,0 +1,8 @@
+-- Write your migrate up statements here
+ALTER TABLE products ADD COLUMN IF NOT EXISTS closed_at TIMESTAMPTZ;
+
+---- create above / drop below ----
+ALTER TABLE products DROP COLUMN IF EXISTS closed_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,36 @@
+-- Write your migrate up statements here
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice FLOAT;
+    highest_bid FLOAT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice INTO product_baseprice
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bids_enforce_increasing_bid
+BEFORE INSERT ON bids
+FOR EACH ROW EXECUTE FUNCTION enforce_increasing_bid ();
+
+---- create above / drop below ----
+DROP TRIGGER IF EXISTS bids_enforce_increasing_bid ON bids;
+
+DROP FUNCTION IF EXISTS enforce_increasing_bid ();
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,73 @@
+-- Write your migrate up statements here
+-- Amounts are stored as integer minor units (cents) of the product currency.
+ALTER TABLE products
+    ALTER COLUMN baseprice TYPE BIGINT USING round(baseprice * 100)::BIGINT,
+    ADD COLUMN IF NOT EXISTS currency CHAR(3) NOT NULL DEFAULT 'USD';
+
+ALTER TABLE bids
+    ALTER COLUMN bid_amount TYPE BIGINT USING round(bid_amount * 100)::BIGINT;
+
+ALTER TABLE sales
+    ALTER COLUMN final_price TYPE BIGINT USING round(final_price * 100)::BIGINT;
+
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    highest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice INTO product_baseprice
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+---- create above / drop below ----
+ALTER TABLE sales
+    ALTER COLUMN final_price TYPE FLOAT USING final_price / 100.0;
+
+ALTER TABLE bids
+    ALTER COLUMN bid_amount TYPE FLOAT USING bid_amount / 100.0;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN baseprice TYPE FLOAT USING baseprice / 100.0;
+
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice FLOAT;
+    highest_bid FLOAT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice INTO product_baseprice
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
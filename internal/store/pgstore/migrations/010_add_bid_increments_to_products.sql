@@ -0,0 +1,9 @@
+-- Write your migrate up statements here
+-- Optional per-auction increment table, NULL means the global default applies.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS bid_increments JSONB;
+
+---- create above / drop below ----
+ALTER TABLE products DROP COLUMN IF EXISTS bid_increments;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,9 @@
+-- Write your migrate up statements here
+-- Hidden minimum sale price, NULL means the auction has no reserve.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS reserve_price BIGINT;
+
+---- create above / drop below ----
+ALTER TABLE products DROP COLUMN IF EXISTS reserve_price;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,80 @@
+-- Write your migrate up statements here
+CREATE TYPE auction_type AS ENUM ('english', 'dutch');
+
+-- Dutch auctions start at baseprice and drop by price_decrement every
+-- decrement_interval_seconds until they reach floor_price.
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS auction_type auction_type NOT NULL DEFAULT 'english',
+    ADD COLUMN IF NOT EXISTS floor_price BIGINT,
+    ADD COLUMN IF NOT EXISTS price_decrement BIGINT,
+    ADD COLUMN IF NOT EXISTS decrement_interval_seconds INTEGER;
+
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    product_auction_type auction_type;
+    highest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice, auction_type INTO product_baseprice, product_auction_type
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    -- The first accepted price wins a dutch auction, there is no second bid.
+    IF product_auction_type = 'dutch' THEN
+        IF highest_bid IS NOT NULL THEN
+            RAISE EXCEPTION 'a dutch auction accepts a single bid'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+---- create above / drop below ----
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    highest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice INTO product_baseprice
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE products
+    DROP COLUMN IF EXISTS decrement_interval_seconds,
+    DROP COLUMN IF EXISTS price_decrement,
+    DROP COLUMN IF EXISTS floor_price,
+    DROP COLUMN IF EXISTS auction_type;
+
+DROP TYPE IF EXISTS auction_type;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,99 @@
+-- Write your migrate up statements here
+ALTER TYPE auction_type ADD VALUE IF NOT EXISTS 'sealed_first_price';
+ALTER TYPE auction_type ADD VALUE IF NOT EXISTS 'sealed_second_price';
+
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    product_auction_type auction_type;
+    highest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice, auction_type INTO product_baseprice, product_auction_type
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    -- Sealed bids are never compared with each other, every bidder gets a
+    -- single bid of at least the base price.
+    IF product_auction_type::text IN ('sealed_first_price', 'sealed_second_price') THEN
+        IF NEW.bid_amount < product_baseprice THEN
+            RAISE EXCEPTION 'bid must be at least the base price'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        IF EXISTS (SELECT 1 FROM bids WHERE product_id = NEW.product_id AND bidder_id = NEW.bidder_id) THEN
+            RAISE EXCEPTION 'a sealed-bid auction accepts a single bid per bidder'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    -- The first accepted price wins a dutch auction, there is no second bid.
+    IF product_auction_type = 'dutch' THEN
+        IF highest_bid IS NOT NULL THEN
+            RAISE EXCEPTION 'a dutch auction accepts a single bid'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+---- create above / drop below ----
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    product_auction_type auction_type;
+    highest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice, auction_type INTO product_baseprice, product_auction_type
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    -- The first accepted price wins a dutch auction, there is no second bid.
+    IF product_auction_type = 'dutch' THEN
+        IF highest_bid IS NOT NULL THEN
+            RAISE EXCEPTION 'a dutch auction accepts a single bid'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Postgres can not drop enum values, the type is rebuilt without them. This
+-- fails while sealed-bid products still exist.
+ALTER TABLE products ALTER COLUMN auction_type DROP DEFAULT;
+ALTER TYPE auction_type RENAME TO auction_type_old;
+CREATE TYPE auction_type AS ENUM ('english', 'dutch');
+ALTER TABLE products
+    ALTER COLUMN auction_type TYPE auction_type USING auction_type::text::auction_type;
+ALTER TABLE products ALTER COLUMN auction_type SET DEFAULT 'english';
+DROP TYPE auction_type_old;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,26 @@
+-- Write your migrate up statements here
+-- Bidding opens at auction_start. Existing auctions opened when they were listed.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS auction_start TIMESTAMPTZ;
+UPDATE products SET auction_start = created_at WHERE auction_start IS NULL;
+ALTER TABLE products
+    ALTER COLUMN auction_start SET NOT NULL,
+    ALTER COLUMN auction_start SET DEFAULT now ();
+
+-- Absentee bids left before an auction opens, applied when it starts.
+CREATE TABLE IF NOT EXISTS pre_bids (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id UUID NOT NULL REFERENCES products (id),
+    bidder_id UUID NOT NULL REFERENCES users (id),
+    amount BIGINT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+    UNIQUE (product_id, bidder_id)
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS pre_bids;
+
+ALTER TABLE products DROP COLUMN IF EXISTS auction_start;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
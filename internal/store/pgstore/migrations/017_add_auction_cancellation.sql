@@ -0,0 +1,11 @@
+-- Write your migrate up statements here
+-- A cancelled auction is closed unsold and every bid placed on it is voided.
+ALTER TABLE products ADD COLUMN IF NOT EXISTS cancelled_at TIMESTAMPTZ;
+ALTER TABLE bids ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ;
+
+---- create above / drop below ----
+ALTER TABLE bids DROP COLUMN IF EXISTS voided_at;
+ALTER TABLE products DROP COLUMN IF EXISTS cancelled_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
@@ -0,0 +1,27 @@
+-- Write your migrate up statements here
+-- A sale event groups products into numbered lots that close one after
+-- another, lot_interval_seconds apart starting at first_lot_end.
+CREATE TABLE IF NOT EXISTS sale_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    seller_id UUID NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    first_lot_end TIMESTAMPTZ NOT NULL,
+    lot_interval_seconds INTEGER NOT NULL CHECK (lot_interval_seconds > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now ()
+);
+
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sale_event_id UUID REFERENCES sale_events (id),
+    ADD COLUMN IF NOT EXISTS lot_number INTEGER,
+    ADD CONSTRAINT products_sale_event_lot_key UNIQUE (sale_event_id, lot_number);
+
+---- create above / drop below ----
+ALTER TABLE products
+    DROP CONSTRAINT IF EXISTS products_sale_event_lot_key,
+    DROP COLUMN IF EXISTS lot_number,
+    DROP COLUMN IF EXISTS sale_event_id;
+
+DROP TABLE IF EXISTS sale_events;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
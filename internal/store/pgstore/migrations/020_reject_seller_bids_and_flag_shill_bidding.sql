@@ -0,0 +1,50 @@
+-- Write your migrate up statements here
+CREATE OR REPLACE FUNCTION reject_seller_bid () RETURNS TRIGGER AS $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM products
+        WHERE id = NEW.product_id AND seller_id = NEW.bidder_id
+    ) THEN
+        RAISE EXCEPTION 'sellers can not bid on their own products'
+            USING ERRCODE = 'insufficient_privilege';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bids_reject_seller_bid
+BEFORE INSERT ON bids
+FOR EACH ROW EXECUTE FUNCTION reject_seller_bid ();
+
+CREATE TYPE shill_flag_reason AS ENUM (
+    'single_seller_bidder',
+    'frequent_retractions',
+    'new_account_price_pushing'
+);
+
+-- Suspicious bidding spotted when an auction ends, kept for moderators to
+-- review. An auction flags each bidder at most once per reason.
+CREATE TABLE IF NOT EXISTS shill_flags (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid (),
+    product_id UUID NOT NULL REFERENCES products (id),
+    bidder_id UUID NOT NULL REFERENCES users (id),
+    seller_id UUID NOT NULL REFERENCES users (id),
+    reason shill_flag_reason NOT NULL,
+    details TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now (),
+    reviewed_at TIMESTAMPTZ,
+    UNIQUE (product_id, bidder_id, reason)
+);
+
+---- create above / drop below ----
+DROP TABLE IF EXISTS shill_flags;
+
+DROP TYPE IF EXISTS shill_flag_reason;
+
+DROP TRIGGER IF EXISTS bids_reject_seller_bid ON bids;
+
+DROP FUNCTION IF EXISTS reject_seller_bid ();
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
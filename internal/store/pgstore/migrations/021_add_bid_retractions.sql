@@ -0,0 +1,160 @@
+-- Write your migrate up statements here
+-- A retracted bid is voided like the bids of a cancelled auction and also
+-- keeps the time it was retracted, which retraction limits are counted on.
+ALTER TABLE bids ADD COLUMN IF NOT EXISTS retracted_at TIMESTAMPTZ;
+
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    product_auction_type auction_type;
+    highest_bid BIGINT;
+    lowest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice, auction_type INTO product_baseprice, product_auction_type
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    -- Sealed bids are never compared with each other, every bidder gets a
+    -- single bid of at least the base price.
+    IF product_auction_type::text IN ('sealed_first_price', 'sealed_second_price') THEN
+        IF NEW.bid_amount < product_baseprice THEN
+            RAISE EXCEPTION 'bid must be at least the base price'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        IF EXISTS (SELECT 1 FROM bids WHERE product_id = NEW.product_id AND bidder_id = NEW.bidder_id AND voided_at IS NULL) THEN
+            RAISE EXCEPTION 'a sealed-bid auction accepts a single bid per bidder'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    -- Multi-unit bids compete for a share of the units, a bid only has to
+    -- beat the base price here and the clearing price is checked in the
+    -- service.
+    IF product_auction_type::text IN ('multi_unit_uniform', 'multi_unit_discriminatory') THEN
+        IF NEW.bid_amount <= product_baseprice THEN
+            RAISE EXCEPTION 'bid must be greater than the base price'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    -- Reverse auctions start at a ceiling price and every bid must undercut
+    -- the lowest one.
+    IF product_auction_type::text = 'reverse' THEN
+        SELECT min(bid_amount) INTO lowest_bid
+        FROM bids
+        WHERE product_id = NEW.product_id AND voided_at IS NULL;
+
+        IF NEW.bid_amount <= 0 OR NEW.bid_amount >= LEAST(product_baseprice, lowest_bid) THEN
+            RAISE EXCEPTION 'bid must be lower than the ceiling price and the lowest bid'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    -- Retracted bids are voided and no longer count, the price falls back
+    -- to the remaining ones.
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id AND voided_at IS NULL;
+
+    -- The first accepted price wins a dutch auction, there is no second bid.
+    IF product_auction_type = 'dutch' THEN
+        IF highest_bid IS NOT NULL THEN
+            RAISE EXCEPTION 'a dutch auction accepts a single bid'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+---- create above / drop below ----
+CREATE OR REPLACE FUNCTION enforce_increasing_bid () RETURNS TRIGGER AS $$
+DECLARE
+    product_baseprice BIGINT;
+    product_auction_type auction_type;
+    highest_bid BIGINT;
+    lowest_bid BIGINT;
+BEGIN
+    -- Locking the product row serializes every bid placed on it.
+    SELECT baseprice, auction_type INTO product_baseprice, product_auction_type
+    FROM products
+    WHERE id = NEW.product_id
+    FOR UPDATE;
+
+    -- Sealed bids are never compared with each other, every bidder gets a
+    -- single bid of at least the base price.
+    IF product_auction_type::text IN ('sealed_first_price', 'sealed_second_price') THEN
+        IF NEW.bid_amount < product_baseprice THEN
+            RAISE EXCEPTION 'bid must be at least the base price'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        IF EXISTS (SELECT 1 FROM bids WHERE product_id = NEW.product_id AND bidder_id = NEW.bidder_id) THEN
+            RAISE EXCEPTION 'a sealed-bid auction accepts a single bid per bidder'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    -- Multi-unit bids compete for a share of the units, a bid only has to
+    -- beat the base price here and the clearing price is checked in the
+    -- service.
+    IF product_auction_type::text IN ('multi_unit_uniform', 'multi_unit_discriminatory') THEN
+        IF NEW.bid_amount <= product_baseprice THEN
+            RAISE EXCEPTION 'bid must be greater than the base price'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    -- Reverse auctions start at a ceiling price and every bid must undercut
+    -- the lowest one.
+    IF product_auction_type::text = 'reverse' THEN
+        SELECT min(bid_amount) INTO lowest_bid
+        FROM bids
+        WHERE product_id = NEW.product_id;
+
+        IF NEW.bid_amount <= 0 OR NEW.bid_amount >= LEAST(product_baseprice, lowest_bid) THEN
+            RAISE EXCEPTION 'bid must be lower than the ceiling price and the lowest bid'
+                USING ERRCODE = 'check_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    SELECT max(bid_amount) INTO highest_bid
+    FROM bids
+    WHERE product_id = NEW.product_id;
+
+    -- The first accepted price wins a dutch auction, there is no second bid.
+    IF product_auction_type = 'dutch' THEN
+        IF highest_bid IS NOT NULL THEN
+            RAISE EXCEPTION 'a dutch auction accepts a single bid'
+                USING ERRCODE = 'unique_violation';
+        END IF;
+        RETURN NEW;
+    END IF;
+
+    IF NEW.bid_amount <= GREATEST(product_baseprice, highest_bid) THEN
+        RAISE EXCEPTION 'bid must be greater than the base price and the highest bid'
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE bids DROP COLUMN IF EXISTS retracted_at;
+
+-- Write your migrate down statements here. If this migration is irreversible
+-- Then delete the separator line above.
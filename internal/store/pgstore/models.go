@@ -5,29 +5,178 @@
 package pgstore
 
 import (
+	"database/sql/driver"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gobid/internal/money"
 )
 
+type AuctionType string
+
+const (
+	AuctionTypeEnglish                 AuctionType = "english"
+	AuctionTypeDutch                   AuctionType = "dutch"
+	AuctionTypeSealedFirstPrice        AuctionType = "sealed_first_price"
+	AuctionTypeSealedSecondPrice       AuctionType = "sealed_second_price"
+	AuctionTypeReverse                 AuctionType = "reverse"
+	AuctionTypeMultiUnitUniform        AuctionType = "multi_unit_uniform"
+	AuctionTypeMultiUnitDiscriminatory AuctionType = "multi_unit_discriminatory"
+)
+
+func (e *AuctionType) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = AuctionType(s)
+	case string:
+		*e = AuctionType(s)
+	default:
+		return fmt.Errorf("unsupported scan type for AuctionType: %T", src)
+	}
+	return nil
+}
+
+type NullAuctionType struct {
+	AuctionType AuctionType `json:"auction_type"`
+	Valid       bool        `json:"valid"` // Valid is true if AuctionType is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullAuctionType) Scan(value interface{}) error {
+	if value == nil {
+		ns.AuctionType, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.AuctionType.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullAuctionType) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.AuctionType), nil
+}
+
+type ShillFlagReason string
+
+const (
+	ShillFlagReasonSingleSellerBidder     ShillFlagReason = "single_seller_bidder"
+	ShillFlagReasonFrequentRetractions    ShillFlagReason = "frequent_retractions"
+	ShillFlagReasonNewAccountPricePushing ShillFlagReason = "new_account_price_pushing"
+)
+
+func (e *ShillFlagReason) Scan(src interface{}) error {
+	switch s := src.(type) {
+	case []byte:
+		*e = ShillFlagReason(s)
+	case string:
+		*e = ShillFlagReason(s)
+	default:
+		return fmt.Errorf("unsupported scan type for ShillFlagReason: %T", src)
+	}
+	return nil
+}
+
+type NullShillFlagReason struct {
+	ShillFlagReason ShillFlagReason `json:"shill_flag_reason"`
+	Valid           bool            `json:"valid"` // Valid is true if ShillFlagReason is not NULL
+}
+
+// Scan implements the Scanner interface.
+func (ns *NullShillFlagReason) Scan(value interface{}) error {
+	if value == nil {
+		ns.ShillFlagReason, ns.Valid = "", false
+		return nil
+	}
+	ns.Valid = true
+	return ns.ShillFlagReason.Scan(value)
+}
+
+// Value implements the driver Valuer interface.
+func (ns NullShillFlagReason) Value() (driver.Value, error) {
+	if !ns.Valid {
+		return nil, nil
+	}
+	return string(ns.ShillFlagReason), nil
+}
+
 type Bid struct {
-	ID        uuid.UUID `json:"id"`
-	ProductID uuid.UUID `json:"product_id"`
-	BidderID  uuid.UUID `json:"bidder_id"`
-	BidAmount float64   `json:"bid_amount"`
-	CreatedAt time.Time `json:"created_at"`
+	ID          uuid.UUID          `json:"id"`
+	ProductID   uuid.UUID          `json:"product_id"`
+	BidderID    uuid.UUID          `json:"bidder_id"`
+	BidAmount   money.Amount       `json:"bid_amount"`
+	CreatedAt   time.Time          `json:"created_at"`
+	VoidedAt    pgtype.Timestamptz `json:"voided_at"`
+	Quantity    int32              `json:"quantity"`
+	RetractedAt pgtype.Timestamptz `json:"retracted_at"`
+}
+
+type MaxBid struct {
+	ID        uuid.UUID    `json:"id"`
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	MaxAmount money.Amount `json:"max_amount"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type PreBid struct {
+	ID        uuid.UUID    `json:"id"`
+	ProductID uuid.UUID    `json:"product_id"`
+	BidderID  uuid.UUID    `json:"bidder_id"`
+	Amount    money.Amount `json:"amount"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
 }
 
 type Product struct {
-	ID          uuid.UUID `json:"id"`
-	SellerID    uuid.UUID `json:"seller_id"`
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
-	IsSold      bool      `json:"is_sold"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID                       uuid.UUID          `json:"id"`
+	SellerID                 uuid.UUID          `json:"seller_id"`
+	ProductName              string             `json:"product_name"`
+	Description              string             `json:"description"`
+	Baseprice                money.Amount       `json:"baseprice"`
+	AuctionEnd               time.Time          `json:"auction_end"`
+	IsSold                   bool               `json:"is_sold"`
+	CreatedAt                time.Time          `json:"created_at"`
+	UpdatedAt                time.Time          `json:"updated_at"`
+	ClosedAt                 pgtype.Timestamptz `json:"closed_at"`
+	Currency                 money.Currency     `json:"currency"`
+	BidIncrements            []byte             `json:"bid_increments"`
+	ReservePrice             *money.Amount      `json:"reserve_price"`
+	BuyNowPrice              *money.Amount      `json:"buy_now_price"`
+	AuctionType              AuctionType        `json:"auction_type"`
+	FloorPrice               *money.Amount      `json:"floor_price"`
+	PriceDecrement           *money.Amount      `json:"price_decrement"`
+	DecrementIntervalSeconds pgtype.Int4        `json:"decrement_interval_seconds"`
+	AuctionStart             time.Time          `json:"auction_start"`
+	CancelledAt              pgtype.Timestamptz `json:"cancelled_at"`
+	Quantity                 int32              `json:"quantity"`
+	SaleEventID              uuid.NullUUID      `json:"sale_event_id"`
+	LotNumber                pgtype.Int4        `json:"lot_number"`
+}
+
+type Sale struct {
+	ID         uuid.UUID    `json:"id"`
+	ProductID  uuid.UUID    `json:"product_id"`
+	BidID      uuid.UUID    `json:"bid_id"`
+	BuyerID    uuid.UUID    `json:"buyer_id"`
+	SellerID   uuid.UUID    `json:"seller_id"`
+	FinalPrice money.Amount `json:"final_price"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Quantity   int32        `json:"quantity"`
+}
+
+type SaleEvent struct {
+	ID                 uuid.UUID `json:"id"`
+	SellerID           uuid.UUID `json:"seller_id"`
+	Name               string    `json:"name"`
+	FirstLotEnd        time.Time `json:"first_lot_end"`
+	LotIntervalSeconds int32     `json:"lot_interval_seconds"`
+	CreatedAt          time.Time `json:"created_at"`
 }
 
 type Session struct {
@@ -36,6 +185,17 @@ type Session struct {
 	Expiry time.Time `json:"expiry"`
 }
 
+type ShillFlag struct {
+	ID         uuid.UUID          `json:"id"`
+	ProductID  uuid.UUID          `json:"product_id"`
+	BidderID   uuid.UUID          `json:"bidder_id"`
+	SellerID   uuid.UUID          `json:"seller_id"`
+	Reason     ShillFlagReason    `json:"reason"`
+	Details    string             `json:"details"`
+	CreatedAt  time.Time          `json:"created_at"`
+	ReviewedAt pgtype.Timestamptz `json:"reviewed_at"`
+}
+
 type User struct {
 	ID           uuid.UUID `json:"id"`
 	UserName     string    `json:"user_name"`
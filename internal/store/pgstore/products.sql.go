@@ -10,8 +10,37 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	"gobid/internal/money"
 )
 
+const cancelProduct = `-- name: CancelProduct :exec
+UPDATE products
+SET is_sold = false, cancelled_at = now(), closed_at = now(), updated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) CancelProduct(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, cancelProduct, id)
+	return err
+}
+
+const closeProduct = `-- name: CloseProduct :exec
+UPDATE products
+SET is_sold = $2, closed_at = now(), updated_at = now()
+WHERE id = $1
+`
+
+type CloseProductParams struct {
+	ID     uuid.UUID `json:"id"`
+	IsSold bool      `json:"is_sold"`
+}
+
+func (q *Queries) CloseProduct(ctx context.Context, arg CloseProductParams) error {
+	_, err := q.db.Exec(ctx, closeProduct, arg.ID, arg.IsSold)
+	return err
+}
+
 const createProduct = `-- name: CreateProduct :one
 INSERT INTO
     products (
@@ -19,34 +48,88 @@ INSERT INTO
         "product_name",
         "description",
         "baseprice",
-        "auction_end"
-    ) VALUES ($1, $2, $3, $4, $5)
-RETURNING id
+        "auction_end",
+        "currency",
+        "bid_increments",
+        "reserve_price",
+        "buy_now_price",
+        "auction_type",
+        "floor_price",
+        "price_decrement",
+        "decrement_interval_seconds",
+        "auction_start",
+        "quantity"
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+RETURNING id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number
 `
 
 type CreateProductParams struct {
-	SellerID    uuid.UUID `json:"seller_id"`
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
+	SellerID                 uuid.UUID      `json:"seller_id"`
+	ProductName              string         `json:"product_name"`
+	Description              string         `json:"description"`
+	Baseprice                money.Amount   `json:"baseprice"`
+	AuctionEnd               time.Time      `json:"auction_end"`
+	Currency                 money.Currency `json:"currency"`
+	BidIncrements            []byte         `json:"bid_increments"`
+	ReservePrice             *money.Amount  `json:"reserve_price"`
+	BuyNowPrice              *money.Amount  `json:"buy_now_price"`
+	AuctionType              AuctionType    `json:"auction_type"`
+	FloorPrice               *money.Amount  `json:"floor_price"`
+	PriceDecrement           *money.Amount  `json:"price_decrement"`
+	DecrementIntervalSeconds pgtype.Int4    `json:"decrement_interval_seconds"`
+	AuctionStart             time.Time      `json:"auction_start"`
+	Quantity                 int32          `json:"quantity"`
 }
 
-func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (uuid.UUID, error) {
+func (q *Queries) CreateProduct(ctx context.Context, arg CreateProductParams) (Product, error) {
 	row := q.db.QueryRow(ctx, createProduct,
 		arg.SellerID,
 		arg.ProductName,
 		arg.Description,
 		arg.Baseprice,
 		arg.AuctionEnd,
+		arg.Currency,
+		arg.BidIncrements,
+		arg.ReservePrice,
+		arg.BuyNowPrice,
+		arg.AuctionType,
+		arg.FloorPrice,
+		arg.PriceDecrement,
+		arg.DecrementIntervalSeconds,
+		arg.AuctionStart,
+		arg.Quantity,
 	)
-	var id uuid.UUID
-	err := row.Scan(&id)
-	return id, err
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.SellerID,
+		&i.ProductName,
+		&i.Description,
+		&i.Baseprice,
+		&i.AuctionEnd,
+		&i.IsSold,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ClosedAt,
+		&i.Currency,
+		&i.BidIncrements,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.AuctionType,
+		&i.FloorPrice,
+		&i.PriceDecrement,
+		&i.DecrementIntervalSeconds,
+		&i.AuctionStart,
+		&i.CancelledAt,
+		&i.Quantity,
+		&i.SaleEventID,
+		&i.LotNumber,
+	)
+	return i, err
 }
 
 const getProductById = `-- name: GetProductById :one
-SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at FROM products
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number FROM products
 WHERE id = $1
 `
 
@@ -63,6 +146,217 @@ func (q *Queries) GetProductById(ctx context.Context, id uuid.UUID) (Product, er
 		&i.IsSold,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.ClosedAt,
+		&i.Currency,
+		&i.BidIncrements,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.AuctionType,
+		&i.FloorPrice,
+		&i.PriceDecrement,
+		&i.DecrementIntervalSeconds,
+		&i.AuctionStart,
+		&i.CancelledAt,
+		&i.Quantity,
+		&i.SaleEventID,
+		&i.LotNumber,
+	)
+	return i, err
+}
+
+const getProductByIdForUpdate = `-- name: GetProductByIdForUpdate :one
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number FROM products
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetProductByIdForUpdate(ctx context.Context, id uuid.UUID) (Product, error) {
+	row := q.db.QueryRow(ctx, getProductByIdForUpdate, id)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.SellerID,
+		&i.ProductName,
+		&i.Description,
+		&i.Baseprice,
+		&i.AuctionEnd,
+		&i.IsSold,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ClosedAt,
+		&i.Currency,
+		&i.BidIncrements,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.AuctionType,
+		&i.FloorPrice,
+		&i.PriceDecrement,
+		&i.DecrementIntervalSeconds,
+		&i.AuctionStart,
+		&i.CancelledAt,
+		&i.Quantity,
+		&i.SaleEventID,
+		&i.LotNumber,
 	)
 	return i, err
 }
+
+const listOpenProducts = `-- name: ListOpenProducts :many
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number FROM products
+WHERE closed_at IS NULL
+ORDER BY auction_end ASC
+`
+
+func (q *Queries) ListOpenProducts(ctx context.Context) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listOpenProducts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Product
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.SellerID,
+			&i.ProductName,
+			&i.Description,
+			&i.Baseprice,
+			&i.AuctionEnd,
+			&i.IsSold,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ClosedAt,
+			&i.Currency,
+			&i.BidIncrements,
+			&i.ReservePrice,
+			&i.BuyNowPrice,
+			&i.AuctionType,
+			&i.FloorPrice,
+			&i.PriceDecrement,
+			&i.DecrementIntervalSeconds,
+			&i.AuctionStart,
+			&i.CancelledAt,
+			&i.Quantity,
+			&i.SaleEventID,
+			&i.LotNumber,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listProductsBySaleEventId = `-- name: ListProductsBySaleEventId :many
+SELECT id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number FROM products
+WHERE sale_event_id = $1
+ORDER BY lot_number ASC
+`
+
+func (q *Queries) ListProductsBySaleEventId(ctx context.Context, saleEventID uuid.NullUUID) ([]Product, error) {
+	rows, err := q.db.Query(ctx, listProductsBySaleEventId, saleEventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Product
+	for rows.Next() {
+		var i Product
+		if err := rows.Scan(
+			&i.ID,
+			&i.SellerID,
+			&i.ProductName,
+			&i.Description,
+			&i.Baseprice,
+			&i.AuctionEnd,
+			&i.IsSold,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.ClosedAt,
+			&i.Currency,
+			&i.BidIncrements,
+			&i.ReservePrice,
+			&i.BuyNowPrice,
+			&i.AuctionType,
+			&i.FloorPrice,
+			&i.PriceDecrement,
+			&i.DecrementIntervalSeconds,
+			&i.AuctionStart,
+			&i.CancelledAt,
+			&i.Quantity,
+			&i.SaleEventID,
+			&i.LotNumber,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setProductLot = `-- name: SetProductLot :one
+UPDATE products
+SET sale_event_id = $2, lot_number = $3, updated_at = now()
+WHERE id = $1
+RETURNING id, seller_id, product_name, description, baseprice, auction_end, is_sold, created_at, updated_at, closed_at, currency, bid_increments, reserve_price, buy_now_price, auction_type, floor_price, price_decrement, decrement_interval_seconds, auction_start, cancelled_at, quantity, sale_event_id, lot_number
+`
+
+type SetProductLotParams struct {
+	ID          uuid.UUID     `json:"id"`
+	SaleEventID uuid.NullUUID `json:"sale_event_id"`
+	LotNumber   pgtype.Int4   `json:"lot_number"`
+}
+
+func (q *Queries) SetProductLot(ctx context.Context, arg SetProductLotParams) (Product, error) {
+	row := q.db.QueryRow(ctx, setProductLot, arg.ID, arg.SaleEventID, arg.LotNumber)
+	var i Product
+	err := row.Scan(
+		&i.ID,
+		&i.SellerID,
+		&i.ProductName,
+		&i.Description,
+		&i.Baseprice,
+		&i.AuctionEnd,
+		&i.IsSold,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.ClosedAt,
+		&i.Currency,
+		&i.BidIncrements,
+		&i.ReservePrice,
+		&i.BuyNowPrice,
+		&i.AuctionType,
+		&i.FloorPrice,
+		&i.PriceDecrement,
+		&i.DecrementIntervalSeconds,
+		&i.AuctionStart,
+		&i.CancelledAt,
+		&i.Quantity,
+		&i.SaleEventID,
+		&i.LotNumber,
+	)
+	return i, err
+}
+
+const updateProductAuctionEnd = `-- name: UpdateProductAuctionEnd :exec
+UPDATE products
+SET auction_end = $2, updated_at = now()
+WHERE id = $1
+`
+
+type UpdateProductAuctionEndParams struct {
+	ID         uuid.UUID `json:"id"`
+	AuctionEnd time.Time `json:"auction_end"`
+}
+
+func (q *Queries) UpdateProductAuctionEnd(ctx context.Context, arg UpdateProductAuctionEndParams) error {
+	_, err := q.db.Exec(ctx, updateProductAuctionEnd, arg.ID, arg.AuctionEnd)
+	return err
+}
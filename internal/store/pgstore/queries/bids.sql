@@ -6,10 +6,66 @@ INSERT INTO bids (
 -- name: GetBidsByProductId :many
 SELECT * FROM bids
 WHERE product_id = $1
-ORDER BY bid_amount DESC;
+ORDER BY bid_amount DESC, created_at ASC;
 
 -- name: GetHighestBidByProductId :one
 SELECT * FROM bids
-WHERE product_id = $1
+WHERE product_id = $1 AND voided_at IS NULL
 ORDER BY bid_amount DESC
 LIMIT 1;
+
+-- name: GetLowestBidByProductId :one
+SELECT * FROM bids
+WHERE product_id = $1 AND voided_at IS NULL
+ORDER BY bid_amount ASC
+LIMIT 1;
+
+-- name: GetBidByBidder :one
+SELECT * FROM bids
+WHERE product_id = $1 AND bidder_id = $2;
+
+-- name: UpdateBidAmount :one
+UPDATE bids
+SET bid_amount = $2, created_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: VoidBidsByProductId :exec
+UPDATE bids
+SET voided_at = now()
+WHERE product_id = $1 AND voided_at IS NULL;
+
+-- name: CreateBidForQuantity :one
+INSERT INTO bids (
+  product_id, bidder_id, bid_amount, quantity
+) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetLatestBidByBidder :one
+SELECT * FROM bids
+WHERE product_id = $1 AND bidder_id = $2 AND voided_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: RetractBid :one
+UPDATE bids
+SET voided_at = now(), retracted_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: LockBidderRetractions :exec
+SELECT pg_advisory_xact_lock(hashtextextended('retractions:' || @bidder_id::uuid::text, 0));
+
+-- name: CountRetractionsByBidderSince :one
+SELECT count(*) FROM bids
+WHERE bidder_id = $1 AND retracted_at >= $2;
+
+-- name: CountBidsByProductId :one
+SELECT count(*) FROM bids
+WHERE product_id = $1 AND voided_at IS NULL;
+
+-- name: ListBidsByProductIdBefore :many
+SELECT * FROM bids
+WHERE product_id = @product_id AND voided_at IS NULL
+  AND (created_at, id) < (@before_created_at::timestamptz, @before_id::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT @page_size;
@@ -0,0 +1,29 @@
+-- name: UpsertPreBid :one
+INSERT INTO pre_bids (
+  product_id, bidder_id, amount
+) VALUES ($1, $2, $3)
+ON CONFLICT (product_id, bidder_id)
+DO UPDATE SET amount = EXCLUDED.amount, updated_at = now()
+RETURNING *;
+
+-- name: PromotePreBidsToMaxBids :exec
+INSERT INTO max_bids (
+  product_id, bidder_id, max_amount, created_at, updated_at
+)
+SELECT product_id, bidder_id, amount, updated_at, updated_at
+FROM pre_bids
+WHERE product_id = $1
+ON CONFLICT (product_id, bidder_id) DO NOTHING;
+
+-- name: PromotePreBidsToBids :exec
+INSERT INTO bids (
+  product_id, bidder_id, bid_amount, created_at
+)
+SELECT product_id, bidder_id, amount, updated_at
+FROM pre_bids
+WHERE product_id = $1
+ORDER BY updated_at ASC;
+
+-- name: DeletePreBidsByProductId :exec
+DELETE FROM pre_bids
+WHERE product_id = $1;
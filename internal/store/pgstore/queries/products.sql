@@ -5,10 +5,56 @@ INSERT INTO
         "product_name",
         "description",
         "baseprice",
-        "auction_end"
-    ) VALUES ($1, $2, $3, $4, $5)
-RETURNING id;
+        "auction_end",
+        "currency",
+        "bid_increments",
+        "reserve_price",
+        "buy_now_price",
+        "auction_type",
+        "floor_price",
+        "price_decrement",
+        "decrement_interval_seconds",
+        "auction_start",
+        "quantity"
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+RETURNING *;
 
 -- name: GetProductById :one
 SELECT * FROM products
 WHERE id = $1;
+
+-- name: ListOpenProducts :many
+SELECT * FROM products
+WHERE closed_at IS NULL
+ORDER BY auction_end ASC;
+
+-- name: GetProductByIdForUpdate :one
+SELECT * FROM products
+WHERE id = $1
+FOR UPDATE;
+
+-- name: CloseProduct :exec
+UPDATE products
+SET is_sold = $2, closed_at = now(), updated_at = now()
+WHERE id = $1;
+
+-- name: UpdateProductAuctionEnd :exec
+UPDATE products
+SET auction_end = $2, updated_at = now()
+WHERE id = $1;
+
+-- name: CancelProduct :exec
+UPDATE products
+SET is_sold = false, cancelled_at = now(), closed_at = now(), updated_at = now()
+WHERE id = $1;
+
+-- name: SetProductLot :one
+UPDATE products
+SET sale_event_id = $2, lot_number = $3, updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: ListProductsBySaleEventId :many
+SELECT * FROM products
+WHERE sale_event_id = $1
+ORDER BY lot_number ASC;
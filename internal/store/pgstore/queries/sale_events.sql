@@ -0,0 +1,8 @@
+-- name: CreateSaleEvent :one
+INSERT INTO sale_events (
+  seller_id, name, first_lot_end, lot_interval_seconds
+) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: GetSaleEventById :one
+SELECT * FROM sale_events
+WHERE id = $1;
@@ -0,0 +1,9 @@
+-- name: CreateSale :one
+INSERT INTO sales (
+  product_id, bid_id, buyer_id, seller_id, final_price
+) VALUES ($1, $2, $3, $4, $5) RETURNING *;
+
+-- name: CreateSaleForQuantity :one
+INSERT INTO sales (
+  product_id, bid_id, buyer_id, seller_id, final_price, quantity
+) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
@@ -0,0 +1,21 @@
+-- name: CreateShillFlag :exec
+INSERT INTO shill_flags (
+  product_id, bidder_id, seller_id, reason, details
+) VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (product_id, bidder_id, reason) DO NOTHING;
+
+-- name: ListBidderActivityByProductId :many
+SELECT
+  u.id AS bidder_id,
+  u.created_at AS account_created_at,
+  (SELECT count(*) FROM bids b
+   WHERE b.bidder_id = u.id AND b.product_id = $1) AS product_bids,
+  (SELECT count(*) FROM bids b
+   WHERE b.bidder_id = u.id) AS total_bids,
+  (SELECT count(*) FROM bids b JOIN products p ON p.id = b.product_id
+   WHERE b.bidder_id = u.id AND p.seller_id = $2) AS seller_bids,
+  (SELECT count(*) FROM bids b JOIN products p ON p.id = b.product_id
+   WHERE b.bidder_id = u.id AND p.seller_id = $2
+     AND b.retracted_at IS NOT NULL) AS seller_retractions
+FROM users u
+WHERE u.id IN (SELECT bidder_id FROM bids WHERE product_id = $1);
@@ -0,0 +1,23 @@
+package bid
+
+import (
+	"context"
+	"gobid/internal/money"
+	"gobid/internal/validator"
+)
+
+type PlaceBidReq struct {
+	Amount money.Amount `json:"amount"`
+	// Quantity is the number of units a bid on a multi-unit auction asks
+	// for, one when left out.
+	Quantity int32 `json:"quantity"`
+}
+
+func (req PlaceBidReq) Valid(ctx context.Context) validator.Evaluator {
+	var eval validator.Evaluator
+
+	eval.CheckField(req.Amount > 0, "amount", "this field must be greater than 0")
+	eval.CheckField(req.Quantity >= 0, "quantity", "this field cannot be negative")
+
+	return eval
+}
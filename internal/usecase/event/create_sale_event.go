@@ -0,0 +1,68 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"gobid/internal/money"
+	"gobid/internal/services"
+	"gobid/internal/validator"
+	"time"
+)
+
+type LotReq struct {
+	ProductName   string                  `json:"product_name"`
+	Description   string                  `json:"description"`
+	Baseprice     money.Amount            `json:"baseprice"`
+	BidIncrements services.IncrementTable `json:"bid_increments"`
+	ReservePrice  *money.Amount           `json:"reserve_price"`
+	BuyNowPrice   *money.Amount           `json:"buy_now_price"`
+}
+
+type CreateSaleEventReq struct {
+	Name               string         `json:"name"`
+	Currency           money.Currency `json:"currency"`
+	AuctionStart       *time.Time     `json:"auction_start"`
+	FirstLotEnd        time.Time      `json:"first_lot_end"`
+	LotIntervalSeconds int32          `json:"lot_interval_seconds"`
+	Lots               []LotReq       `json:"lots"`
+}
+
+const (
+	minFirstLotDuration = 2 * time.Hour
+	maxLots             = 1000
+)
+
+func (req CreateSaleEventReq) Valid(ctx context.Context) validator.Evaluator {
+	var eval validator.Evaluator
+
+	eval.CheckField(validator.NotBlank(req.Name), "name", "this field cannot be empty")
+	eval.CheckField(validator.MaxChars(req.Name, 255), "name", "this field must be at most 255 characters")
+	eval.CheckField(req.Currency == "" || req.Currency.Valid(), "currency", "this currency is not supported")
+	eval.CheckField(req.LotIntervalSeconds > 0, "lot_interval_seconds", "this field must be greater than 0")
+	eval.CheckField(len(req.Lots) > 0 && len(req.Lots) <= maxLots, "lots", fmt.Sprintf("a sale event must have between 1 and %d lots", maxLots))
+
+	auctionStart := time.Now()
+	if req.AuctionStart != nil {
+		eval.CheckField(req.AuctionStart.After(auctionStart), "auction_start", "must be in the future")
+		auctionStart = *req.AuctionStart
+	}
+	eval.CheckField(req.FirstLotEnd.Sub(auctionStart) >= minFirstLotDuration, "first_lot_end", "must be at least 2 hours after the auction start")
+
+	for i, lot := range req.Lots {
+		key := func(field string) string {
+			return fmt.Sprintf("lots[%d].%s", i, field)
+		}
+
+		eval.CheckField(validator.NotBlank(lot.ProductName), key("product_name"), "this field cannot be empty")
+		eval.CheckField(
+			validator.MinChars(lot.Description, 10) &&
+				validator.MaxChars(lot.Description, 255), key("description"), "this field must be between 10 and 255 characters")
+		eval.CheckField(lot.Baseprice > 0, key("baseprice"), "this field must be greater than 0")
+		eval.CheckField(lot.BidIncrements == nil || lot.BidIncrements.Validate() == nil, key("bid_increments"), services.ErrInvalidIncrementTable.Error())
+		eval.CheckField(lot.ReservePrice == nil || *lot.ReservePrice > lot.Baseprice, key("reserve_price"), "this field must be greater than baseprice")
+		eval.CheckField(lot.BuyNowPrice == nil || *lot.BuyNowPrice > lot.Baseprice, key("buy_now_price"), "this field must be greater than baseprice")
+		eval.CheckField(lot.BuyNowPrice == nil || lot.ReservePrice == nil || *lot.BuyNowPrice >= *lot.ReservePrice, key("buy_now_price"), "this field cannot be lower than reserve_price")
+	}
+
+	return eval
+}
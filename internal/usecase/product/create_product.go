@@ -2,6 +2,9 @@ package product
 
 import (
 	"context"
+	"gobid/internal/money"
+	"gobid/internal/services"
+	"gobid/internal/store/pgstore"
 	"gobid/internal/validator"
 	"time"
 
@@ -9,11 +12,23 @@ import (
 )
 
 type CreateProductReq struct {
-	SellerID    uuid.UUID `json:"seller_id"`
-	ProductName string    `json:"product_name"`
-	Description string    `json:"description"`
-	Baseprice   float64   `json:"baseprice"`
-	AuctionEnd  time.Time `json:"auction_end"`
+	SellerID     uuid.UUID      `json:"seller_id"`
+	ProductName  string         `json:"product_name"`
+	Description  string         `json:"description"`
+	Baseprice    money.Amount   `json:"baseprice"`
+	Currency     money.Currency `json:"currency"`
+	AuctionStart *time.Time     `json:"auction_start"`
+	AuctionEnd   time.Time      `json:"auction_end"`
+
+	BidIncrements services.IncrementTable `json:"bid_increments"`
+	ReservePrice  *money.Amount           `json:"reserve_price"`
+	BuyNowPrice   *money.Amount           `json:"buy_now_price"`
+	Quantity      int32                   `json:"quantity"`
+
+	AuctionType              pgstore.AuctionType `json:"auction_type"`
+	FloorPrice               *money.Amount       `json:"floor_price"`
+	PriceDecrement           *money.Amount       `json:"price_decrement"`
+	DecrementIntervalSeconds int32               `json:"decrement_interval_seconds"`
 }
 
 const minAuctionDuration = 2 * time.Hour
@@ -27,7 +42,51 @@ func (req CreateProductReq) Valid(ctx context.Context) validator.Evaluator {
 		validator.MinChars(req.Description, 10) &&
 			validator.MaxChars(req.Description, 255), "description", "this field must be between 10 and 255 characters")
 	eval.CheckField(req.Baseprice > 0, "baseprice", "this field must be greater than 0")
-	eval.CheckField(req.AuctionEnd.Sub(time.Now()) >= minAuctionDuration, "auction_end", "must be at least 2 hours duration")
+	eval.CheckField(req.Currency == "" || req.Currency.Valid(), "currency", "this currency is not supported")
+	eval.CheckField(req.BidIncrements == nil || req.BidIncrements.Validate() == nil, "bid_increments", services.ErrInvalidIncrementTable.Error())
+	eval.CheckField(req.ReservePrice == nil || *req.ReservePrice > req.Baseprice, "reserve_price", "this field must be greater than baseprice")
+	eval.CheckField(req.BuyNowPrice == nil || *req.BuyNowPrice > req.Baseprice, "buy_now_price", "this field must be greater than baseprice")
+	eval.CheckField(req.BuyNowPrice == nil || req.ReservePrice == nil || *req.BuyNowPrice >= *req.ReservePrice, "buy_now_price", "this field cannot be lower than reserve_price")
+	auctionStart := time.Now()
+	if req.AuctionStart != nil {
+		eval.CheckField(req.AuctionStart.After(auctionStart), "auction_start", "must be in the future")
+		auctionStart = *req.AuctionStart
+	}
+	eval.CheckField(req.AuctionEnd.Sub(auctionStart) >= minAuctionDuration, "auction_end", "must be at least 2 hours duration")
+
+	if !services.IsMultiUnit(req.AuctionType) {
+		eval.CheckField(req.Quantity == 0 || req.Quantity == 1, "quantity", "only multi-unit auctions sell more than one unit")
+	}
+
+	switch req.AuctionType {
+	case "", pgstore.AuctionTypeEnglish:
+	case pgstore.AuctionTypeDutch:
+		eval.CheckField(req.FloorPrice != nil && *req.FloorPrice > 0 && *req.FloorPrice < req.Baseprice, "floor_price", "this field must be greater than 0 and lower than baseprice")
+		eval.CheckField(req.PriceDecrement != nil && *req.PriceDecrement > 0, "price_decrement", "this field must be greater than 0")
+		eval.CheckField(req.DecrementIntervalSeconds > 0, "decrement_interval_seconds", "this field must be greater than 0")
+		eval.CheckField(req.BidIncrements == nil, "bid_increments", "not available for dutch auctions")
+		eval.CheckField(req.ReservePrice == nil, "reserve_price", "not available for dutch auctions")
+		eval.CheckField(req.BuyNowPrice == nil, "buy_now_price", "not available for dutch auctions")
+	case pgstore.AuctionTypeSealedFirstPrice, pgstore.AuctionTypeSealedSecondPrice:
+		eval.CheckField(req.BidIncrements == nil, "bid_increments", "not available for sealed-bid auctions")
+		eval.CheckField(req.BuyNowPrice == nil, "buy_now_price", "not available for sealed-bid auctions")
+	case pgstore.AuctionTypeReverse:
+		// The first bid has to undercut the ceiling by one increment, and
+		// still be worth something.
+		increments := req.BidIncrements
+		if increments == nil {
+			increments = services.DefaultIncrementTable
+		}
+		eval.CheckField(req.Baseprice > increments.IncrementFor(req.Baseprice), "baseprice", "this field must be greater than the bid increment of reverse auctions")
+		eval.CheckField(req.ReservePrice == nil, "reserve_price", "not available for reverse auctions")
+		eval.CheckField(req.BuyNowPrice == nil, "buy_now_price", "not available for reverse auctions")
+	case pgstore.AuctionTypeMultiUnitUniform, pgstore.AuctionTypeMultiUnitDiscriminatory:
+		eval.CheckField(req.Quantity > 1, "quantity", "this field must be greater than 1")
+		eval.CheckField(req.ReservePrice == nil, "reserve_price", "not available for multi-unit auctions")
+		eval.CheckField(req.BuyNowPrice == nil, "buy_now_price", "not available for multi-unit auctions")
+	default:
+		eval.AddFieldError("auction_type", "this auction type is not supported")
+	}
 
 	return eval
 }
@@ -0,0 +1,43 @@
+package product
+
+import (
+	"context"
+	"gobid/internal/money"
+	"gobid/internal/services"
+	"gobid/internal/store/pgstore"
+	"testing"
+	"time"
+)
+
+func TestReverseAuctionCeiling(t *testing.T) {
+	tests := []struct {
+		name       string
+		baseprice  money.Amount
+		increments services.IncrementTable
+		valid      bool
+	}{
+		{name: "above the default increment", baseprice: 1_01, valid: true},
+		{name: "equal to the default increment", baseprice: 1_00},
+		{name: "below the default increment", baseprice: 50},
+		{name: "above its own increment", baseprice: 20_00, increments: services.IncrementTable{{Increment: 10_00}}, valid: true},
+		{name: "below its own increment", baseprice: 5_00, increments: services.IncrementTable{{Increment: 10_00}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := CreateProductReq{
+				ProductName:   "Office chairs",
+				Description:   "Forty office chairs delivered by March",
+				Baseprice:     tt.baseprice,
+				AuctionEnd:    time.Now().Add(24 * time.Hour),
+				AuctionType:   pgstore.AuctionTypeReverse,
+				BidIncrements: tt.increments,
+			}
+
+			problems := req.Valid(context.Background())
+			if _, refused := problems["baseprice"]; refused == tt.valid {
+				t.Errorf("got problems %v, want a valid ceiling: %v", problems, tt.valid)
+			}
+		})
+	}
+}